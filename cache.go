@@ -0,0 +1,104 @@
+package redisutil
+
+import "context"
+
+// Cache 基于 RedisUtil 的类型安全封装, T 为缓存值的类型, 不需要传指针和反射
+type Cache[T any] struct {
+	redisUtil *RedisUtil
+	codec     Codec
+}
+
+// NewCache codec 为空时使用 RedisUtil 的默认 codec
+func NewCache[T any](redisUtil *RedisUtil, codec Codec) *Cache[T] {
+	return &Cache[T]{redisUtil: redisUtil, codec: codec}
+}
+
+func (c *Cache[T]) RedisUtil() *RedisUtil {
+	return c.redisUtil
+}
+
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl int) error {
+	return c.redisUtil.set(ctx, key, value, ttl, c.redisUtil.getValueCodec(c.codec))
+}
+
+func (c *Cache[T]) Get(ctx context.Context, key string) (value T, hit bool, err error) {
+	hit, err = c.redisUtil.get(ctx, key, &value, c.redisUtil.getValueCodec(c.codec))
+
+	return value, hit, err
+}
+
+// MGet values, hits 和 keys 一一对应
+func (c *Cache[T]) MGet(ctx context.Context, keys []string) (values []T, hits []bool, err error) {
+	values = make([]T, len(keys))
+
+	hits, err = c.redisUtil.mget(ctx, keys, &values, c.redisUtil.getValueCodec(c.codec))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return values, hits, nil
+}
+
+func (c *Cache[T]) Del(ctx context.Context, key string) error {
+	return c.redisUtil.Del(ctx, key)
+}
+
+// Wrap 同 CacheWrapper, params 中的 Result, FallbackFunc 不需要设置, Stale 等输出字段会写回 params
+func (c *Cache[T]) Wrap(ctx context.Context,
+	params *WrapperParams, fallback func() (T, error)) (result T, err error) {
+	wrapperParams := *params
+	wrapperParams.Result = &result
+	wrapperParams.FallbackFunc = func() (interface{}, error) {
+		return fallback()
+	}
+
+	if wrapperParams.Codec == nil {
+		wrapperParams.Codec = c.codec
+	}
+
+	err = c.redisUtil.CacheWrapper(ctx, &wrapperParams)
+
+	params.Stale = wrapperParams.Stale
+
+	return result, err
+}
+
+// WrapMany 同 CacheWrapperMget 的批量获取, params 中的 ResultSlice, FallbackFuncSlice, BatchFallbackFunc 不需要设置
+// fallback 返回 ErrNotFound 表示全部不存在, Found, NotFound, Stale 等输出字段会写回 params
+func (c *Cache[T]) WrapMany(ctx context.Context,
+	params *WrapperParamsMget, fallback func(fallbackIndexes []int) (map[int]T, error)) ([]T, error) {
+	results := make([]T, len(params.Keys))
+
+	wrapperParams := *params
+	wrapperParams.ResultSlice = &results
+	wrapperParams.FallbackFuncSlice = nil
+	wrapperParams.BatchFallbackFunc = func(fallbackIndexes []int) (map[int]interface{}, error) {
+		data, err := fallback(fallbackIndexes)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[int]interface{}, len(data))
+		for i, value := range data {
+			result[i] = value
+		}
+
+		return result, nil
+	}
+
+	if wrapperParams.Codec == nil {
+		wrapperParams.Codec = c.codec
+	}
+
+	err := c.redisUtil.CacheWrapperMget(ctx, &wrapperParams)
+
+	params.Found = wrapperParams.Found
+	params.NotFound = wrapperParams.NotFound
+	params.Stale = wrapperParams.Stale
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
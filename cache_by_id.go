@@ -0,0 +1,96 @@
+package redisutil
+
+import "context"
+
+// WrapperParamsByID 按业务 ID 批量获取缓存, 不需要维护 keys 和下标的对应关系
+type WrapperParamsByID[ID comparable, V any] struct {
+	IDs           []ID
+	KeyFunc       func(id ID) string // 由 ID 生成缓存 key
+	ExpireSeconds int
+
+	Loader func(ids []ID) (map[ID]V, error) // 只会传入缓存未命中的 ID
+
+	SingleFlight bool // 是否启动 singleflight
+	FlushCache   bool // 是否用 Loader 刷新缓存
+
+	Codec Codec // 为空时使用默认 codec
+
+	// Loader 没有返回的 ID 写入不存在的标记, 过期时间为 NotFoundExpireSeconds, 同 WrapperParams
+	CacheNotFound         bool
+	NotFoundExpireSeconds int
+}
+
+// CacheWrapperByID 同 CacheWrapperMget 的批量获取, 返回找到的值和不存在的 ID, 重复的 ID 只获取一次
+// 结果通过返回值输出, 不会写入 params, params 可以在并发的调用间共享
+func CacheWrapperByID[ID comparable, V any](ctx context.Context,
+	ru *RedisUtil, params *WrapperParamsByID[ID, V]) (found map[ID]V, missing []ID, err error) {
+	ids := make([]ID, 0, len(params.IDs))
+	idSet := make(map[ID]struct{}, len(params.IDs))
+
+	for _, id := range params.IDs {
+		if _, ok := idSet[id]; !ok {
+			idSet[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+
+	keys := make([]string, len(ids))
+	expireSeconds := make([]int, len(ids))
+
+	for i, id := range ids {
+		keys[i] = params.KeyFunc(id)
+		expireSeconds[i] = params.ExpireSeconds
+	}
+
+	results := make([]V, len(ids))
+
+	mgetParams := &WrapperParamsMget{
+		Keys:          keys,
+		ExpireSeconds: expireSeconds,
+		ResultSlice:   &results,
+		BatchFallbackFunc: func(fallbackIndexes []int) (map[int]interface{}, error) {
+			loadIDs := make([]ID, len(fallbackIndexes))
+			for j, fallbackIndex := range fallbackIndexes {
+				loadIDs[j] = ids[fallbackIndex]
+			}
+
+			data, err := params.Loader(loadIDs)
+			if err != nil {
+				return nil, err
+			}
+
+			result := make(map[int]interface{}, len(fallbackIndexes))
+
+			for _, fallbackIndex := range fallbackIndexes {
+				if value, ok := data[ids[fallbackIndex]]; ok {
+					result[fallbackIndex] = value
+				} else if params.CacheNotFound {
+					result[fallbackIndex] = ErrNotFound
+				}
+			}
+
+			return result, nil
+		},
+		SingleFlight:          params.SingleFlight,
+		FlushCache:            params.FlushCache,
+		Codec:                 params.Codec,
+		NotFoundExpireSeconds: params.NotFoundExpireSeconds,
+	}
+
+	if err = ru.CacheWrapperMget(ctx, mgetParams); err != nil {
+		return nil, nil, err
+	}
+
+	found = make(map[ID]V, len(ids))
+	missing = make([]ID, 0)
+
+	for i, id := range ids {
+		if mgetParams.Found[i] {
+			found[id] = results[i]
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return found, missing, nil
+}
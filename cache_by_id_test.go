@@ -0,0 +1,74 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheWrapperByID(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	type user struct {
+		ID   int64
+		Name string
+	}
+
+	keyFunc := func(id int64) string {
+		return fmt.Sprintf("gotest:redis_util:user:%d", id)
+	}
+
+	defer func() {
+		for id := int64(1); id <= 3; id++ {
+			_ = redisUtil.Del(ctx, keyFunc(id))
+		}
+	}()
+
+	loadedIDs := make([][]int64, 0)
+
+	params := &WrapperParamsByID[int64, *user]{
+		IDs:           []int64{1, 2, 3, 1},
+		KeyFunc:       keyFunc,
+		ExpireSeconds: 600,
+		Loader: func(ids []int64) (map[int64]*user, error) {
+			loadedIDs = append(loadedIDs, ids)
+
+			result := make(map[int64]*user)
+
+			for _, id := range ids {
+				if id != 3 { // 3 不存在
+					result[id] = &user{ID: id, Name: fmt.Sprintf("name%d", id)}
+				}
+			}
+
+			return result, nil
+		},
+		CacheNotFound: true,
+	}
+
+	for i := 0; i < 2; i++ {
+		found, missing, err := CacheWrapperByID(ctx, redisUtil, params)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, len(found))
+		assert.Equal(t, "name1", found[1].Name)
+		assert.Equal(t, "name2", found[2].Name)
+		assert.Equal(t, []int64{3}, missing)
+	}
+
+	// 重复的 ID 只获取一次, 不存在的 ID 已经缓存
+	assert.Equal(t, [][]int64{{1, 2, 3}}, loadedIDs)
+
+	_ = redisUtil.Del(ctx, keyFunc(2))
+	params.IDs = []int64{2}
+
+	found, missing, err := CacheWrapperByID(ctx, redisUtil, params)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "name2", found[2].Name)
+	assert.Equal(t, 0, len(missing))
+	assert.Equal(t, [][]int64{{1, 2, 3}, {2}}, loadedIDs)
+}
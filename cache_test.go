@@ -0,0 +1,121 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	type valueStruct struct {
+		Name string
+		Age  int
+	}
+
+	cache := NewCache[*valueStruct](NewRedisUtil(getTestPool()), &JSONCodec{})
+	keys := []string{"gotest:redis_util:cache1", "gotest:redis_util:cache2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = cache.Del(ctx, key)
+		}
+	}()
+
+	err := cache.Set(ctx, keys[0], &valueStruct{Name: "name0", Age: 18}, 600)
+	assert.Equal(t, nil, err)
+
+	value, hit, err := cache.Get(ctx, keys[0])
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, &valueStruct{Name: "name0", Age: 18}, value)
+
+	values, hits, err := cache.MGet(ctx, keys)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false}, hits)
+	assert.Equal(t, "name0", values[0].Name)
+	assert.Nil(t, values[1])
+
+	value, err = cache.Wrap(ctx, &WrapperParams{Key: keys[1], ExpireSeconds: 600},
+		func() (*valueStruct, error) {
+			return &valueStruct{Name: "name1"}, nil
+		})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "name1", value.Name)
+
+	_ = cache.Del(ctx, keys[1])
+
+	fallbackIndexes := make([]int, 0)
+
+	mgetParams := &WrapperParamsMget{Keys: keys, ExpireSeconds: []int{600, 600}}
+
+	values, err = cache.WrapMany(ctx, mgetParams, func(indexes []int) (map[int]*valueStruct, error) {
+		fallbackIndexes = append(fallbackIndexes, indexes...)
+
+		return map[int]*valueStruct{1: {Name: "name1"}}, nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{1}, fallbackIndexes)
+	assert.Equal(t, "name0", values[0].Name)
+	assert.Equal(t, "name1", values[1].Name)
+	assert.Equal(t, []bool{true, true}, mgetParams.Found)
+
+	// fallback 没有返回的 key, Found 为 false
+	_ = cache.Del(ctx, keys[1])
+
+	values, err = cache.WrapMany(ctx, mgetParams, func(indexes []int) (map[int]*valueStruct, error) {
+		return map[int]*valueStruct{}, nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Nil(t, values[1])
+	assert.Equal(t, []bool{true, false}, mgetParams.Found)
+
+	// 不存在
+	params := &WrapperParams{Key: keys[0], ExpireSeconds: 600, FlushCache: true}
+	_, err = cache.Wrap(ctx, params, func() (*valueStruct, error) {
+		return nil, ErrNotFound
+	})
+	assert.True(t, IsNotFound(err))
+
+	_, hit, _ = cache.Get(ctx, keys[0])
+	assert.False(t, hit)
+}
+
+func TestCacheInterfaceNil(t *testing.T) {
+	ctx := context.Background()
+
+	cache := NewCache[interface{}](NewRedisUtil(getTestPool()), &JSONCodec{})
+	keys := []string{"gotest:redis_util:cache_nil1", "gotest:redis_util:cache_nil2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = cache.Del(ctx, key)
+		}
+	}()
+
+	fallbackCount := 0
+
+	// 结果是接口类型时 fallback 返回 nil
+	for i := 0; i < 2; i++ {
+		value, err := cache.Wrap(ctx, &WrapperParams{Key: keys[0], ExpireSeconds: 600},
+			func() (interface{}, error) {
+				fallbackCount++
+
+				return nil, nil
+			})
+		assert.Equal(t, nil, err)
+		assert.Nil(t, value)
+	}
+
+	assert.Equal(t, 1, fallbackCount)
+
+	values, err := cache.WrapMany(ctx, &WrapperParamsMget{Keys: keys, ExpireSeconds: []int{600, 600}},
+		func(indexes []int) (map[int]interface{}, error) {
+			return map[int]interface{}{1: nil}, nil
+		})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []interface{}{nil, nil}, values)
+}
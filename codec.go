@@ -0,0 +1,338 @@
+package redisutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/cclehui/redisutil/internal/base"
+	"github.com/pkg/errors"
+)
+
+// Codec 缓存值的编解码, 通过 OptionCodec 设置默认 codec, WrapperParams 等参数中可单次覆盖
+type Codec interface {
+	Name() string
+	Encode(value interface{}) ([]byte, error)
+	Decode(data []byte, ptr interface{}) error
+}
+
+const (
+	CodecNameGob  = "gob"
+	CodecNameJSON = "json"
+	CodecNameRaw  = "raw"
+)
+
+var ErrRawCodecType = errors.New("raw codec only support string and []byte")
+
+var defaultCodec Codec = &GobCodec{}
+
+func GetDefaultCodec() Codec {
+	return defaultCodec
+}
+
+// GobCodec encoding/gob 编码, 只有 go 能解析
+type GobCodec struct{}
+
+func (c *GobCodec) Name() string {
+	return CodecNameGob
+}
+
+func (c *GobCodec) Encode(value interface{}) ([]byte, error) {
+	return base.Encode(value)
+}
+
+func (c *GobCodec) Decode(data []byte, ptr interface{}) error {
+	return base.Decode(data, ptr)
+}
+
+// JSONCodec json 编码, 可以跨语言读取
+type JSONCodec struct{}
+
+func (c *JSONCodec) Name() string {
+	return CodecNameJSON
+}
+
+func (c *JSONCodec) Encode(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return data, nil
+}
+
+func (c *JSONCodec) Decode(data []byte, ptr interface{}) error {
+	if err := json.Unmarshal(data, ptr); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// RawCodec 原样存储 string 和 []byte
+type RawCodec struct{}
+
+func (c *RawCodec) Name() string {
+	return CodecNameRaw
+}
+
+func (c *RawCodec) Encode(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case *string:
+		return []byte(*v), nil
+	case *[]byte:
+		return *v, nil
+	default:
+		return nil, errors.WithMessage(ErrRawCodecType, fmt.Sprintf("value type:%T", value))
+	}
+}
+
+func (c *RawCodec) Decode(data []byte, ptr interface{}) error {
+	switch v := ptr.(type) {
+	case *string:
+		*v = string(data)
+	case *[]byte:
+		*v = append([]byte{}, data...)
+	case **string:
+		s := string(data)
+		*v = &s
+	case **[]byte:
+		b := append([]byte{}, data...)
+		*v = &b
+	default:
+		return errors.WithMessage(ErrRawCodecType, fmt.Sprintf("ptr type:%T", ptr))
+	}
+
+	return nil
+}
+
+// valueCodec 缓存值的编解码流程, 整数直接存储字符串形式 (兼容 INCR 等命令), 其他类型使用 codec
+// 注意: 旧版本 BatchSet 使用 gob 编码整数, 现在和 Set 一样存储字符串, 见 README 升级说明
+// 开启信封时写入 codec id 和 schema version, 读取时总是优先按信封中的 codec 解码
+// 超过压缩阈值的数据压缩后总是带信封写入
+type valueCodec struct {
+	codec Codec
+
+	envelope      bool
+	schemaVersion uint32
+
+	compressor        Compressor
+	compressThreshold int
+}
+
+// codec 为nil 时使用 RedisUtil 的默认 codec
+func (ru *RedisUtil) getValueCodec(codec Codec) *valueCodec {
+	if codec == nil {
+		codec = ru.getCodec()
+	}
+
+	return &valueCodec{
+		codec:         codec,
+		envelope:      ru.envelope,
+		schemaVersion: ru.schemaVersion,
+
+		compressor:        ru.compressor,
+		compressThreshold: ru.compressThreshold,
+	}
+}
+
+// list, set 的成员按编码后的字节比较(LRem, SIsMember 等), 不使用信封和压缩, 保证相同的值总是编码成相同的字节
+// 不受 OptionEnvelope 的 schema version, OptionCompress 的影响, 已经带信封写入的成员仍然可以读取
+func (ru *RedisUtil) getMemberCodec() *valueCodec {
+	return &valueCodec{codec: ru.getCodec()}
+}
+
+func (vc *valueCodec) encode(value interface{}) ([]byte, error) {
+	return vc.encodeWithMeta(value, nil)
+}
+
+// 有 meta 时总是带信封写入, 整数也一样, meta 为不存在的标记时忽略 value
+func (vc *valueCodec) encodeWithMeta(value interface{}, meta *valueMeta) ([]byte, error) {
+	if meta != nil && meta.notFound {
+		return vc.encodeTombstone()
+	}
+
+	var (
+		payload []byte
+		err     error
+	)
+
+	s, isNumValue := isNum(value)
+
+	if isNumValue {
+		if meta == nil {
+			return []byte(s), nil
+		}
+
+		payload = []byte(s)
+	} else if payload, err = vc.codec.Encode(value); err != nil {
+		return nil, err
+	}
+
+	needCompress := !isNumValue && vc.compressor != nil && len(payload) >= vc.compressThreshold
+
+	// 编码结果恰好以信封的 magic 开头时(例如 RawCodec 的二进制数据)同样带信封写入, 读取时以 magic 开头的一定是信封
+	if !vc.envelope && !needCompress && meta == nil && !isEnvelope(payload) {
+		return payload, nil
+	}
+
+	id, err := codecID(vc.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{codecID: id, schemaVersion: vc.schemaVersion, meta: meta, payload: payload}
+
+	if needCompress {
+		var compressID uint8
+
+		if compressID, err = compressorID(vc.compressor); err != nil {
+			return nil, err
+		}
+
+		if env.payload, err = vc.compressor.Compress(payload); err != nil {
+			return nil, err
+		}
+
+		env.setCompressed(compressID)
+	}
+
+	return env.marshal(), nil
+}
+
+// 不存在的标记, 只在 CacheWrapper 中使用
+func (vc *valueCodec) encodeTombstone() ([]byte, error) {
+	id, err := codecID(vc.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{codecID: id, schemaVersion: vc.schemaVersion, meta: &valueMeta{notFound: true}}
+
+	return env.marshal(), nil
+}
+
+// schema version 不一致时 hit 为false, 当作缓存未命中处理, 不存在的标记和逻辑过期的值同样当作未命中
+func (vc *valueCodec) decode(data []byte, ptr interface{}) (hit bool, err error) {
+	hit, meta, err := vc.decodeWithMeta(data, ptr)
+
+	return hit && !meta.notFound && !meta.expired(), err
+}
+
+// 没有信封或信封中没有 meta 时 meta 为空结构, 不存在的标记 hit 为 true, 不会写入 ptr
+func (vc *valueCodec) decodeWithMeta(data []byte, ptr interface{}) (hit bool, meta *valueMeta, err error) {
+	meta = &valueMeta{}
+
+	if !isEnvelope(data) { // 没有信封的数据
+		if isNumPtr(ptr) {
+			hit, err = decodeResult(decodeNum(vc.codec, data, ptr))
+		} else {
+			hit, err = decodeResult(vc.codec.Decode(data, ptr))
+		}
+
+		return hit, meta, err
+	}
+
+	env, err := unmarshalEnvelope(data)
+	if err != nil {
+		return false, meta, err
+	}
+
+	if env.meta != nil {
+		meta = env.meta
+	}
+
+	if vc.envelope && env.schemaVersion != vc.schemaVersion {
+		return false, meta, nil
+	}
+
+	if meta.notFound {
+		return true, meta, nil
+	}
+
+	codec, err := codecByID(env.codecID)
+	if err != nil {
+		return false, meta, err
+	}
+
+	payload := env.payload
+
+	if env.compressed() {
+		var compressor Compressor
+
+		if compressor, err = compressorByID(env.compressorID()); err != nil {
+			return false, meta, err
+		}
+
+		if payload, err = compressor.Decompress(payload); err != nil {
+			return false, meta, err
+		}
+	}
+
+	if isNumPtr(ptr) {
+		hit, err = decodeResult(decodeNum(codec, payload, ptr))
+	} else {
+		hit, err = decodeResult(codec.Decode(payload, ptr))
+	}
+
+	return hit, meta, err
+}
+
+// valuesInter 必须是 slice 的指针, 结果覆盖原来的 slice, 未命中的元素为零值
+func (vc *valueCodec) decodeSlice(datas [][]byte, valuesInter interface{}) error {
+	valuesRF := reflect.ValueOf(valuesInter)
+	if valuesRF.Kind() != reflect.Ptr || valuesRF.Elem().Kind() != reflect.Slice {
+		return errors.New(fmt.Sprintf("valuesInter is not ptr of slice: %T", valuesInter))
+	}
+
+	sliceRF := reflect.MakeSlice(valuesRF.Elem().Type(), len(datas), len(datas))
+
+	for i, data := range datas {
+		if data == nil {
+			continue
+		}
+
+		if _, err := vc.decode(data, sliceRF.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+
+	valuesRF.Elem().Set(sliceRF)
+
+	return nil
+}
+
+// 整数优先按十进制字符串解析, 失败时再按 codec 解码
+// 兼容旧版本 BatchSet, CacheWrapperMget 写入的 gob 编码的整数
+func decodeNum(codec Codec, data []byte, ptr interface{}) error {
+	err := bytesToNum(data, ptr)
+	if err == nil {
+		return nil
+	}
+
+	if codec.Decode(data, ptr) == nil {
+		return nil
+	}
+
+	return err
+}
+
+func decodeResult(err error) (hit bool, resErr error) {
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (ru *RedisUtil) getCodec() Codec {
+	if ru.codec != nil {
+		return ru.codec
+	}
+
+	return GetDefaultCodec()
+}
@@ -0,0 +1,150 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecJSON(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionCodec(&JSONCodec{}))
+	redisKey := "gotest:redis_util:codec_json"
+
+	type valueStruct struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	data := &valueStruct{Name: "TestCodecJSON", Age: 18}
+
+	err := redisUtil.Set(ctx, redisKey, data, 600)
+	assert.Equal(t, nil, err)
+
+	// 其他语言可以直接读取
+	var rawValue string
+
+	err = redisUtil.WrapDo(ctx, func(con redis.Conn) error {
+		rawValue, err = redis.String(con.Do("GET", redisKey))
+
+		return err
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"name":"TestCodecJSON","age":18}`, rawValue)
+
+	result := &valueStruct{}
+	hit, err := redisUtil.Get(ctx, redisKey, result)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+	assert.Equal(t, data, result)
+
+	mgetResult := make([]*valueStruct, 2)
+	hits, err := redisUtil.MGet(ctx, []string{redisKey, "gotest:redis_util:codec_json_none"}, &mgetResult)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false}, hits)
+	assert.Equal(t, data, mgetResult[0])
+	assert.Nil(t, mgetResult[1])
+
+	_ = redisUtil.Del(ctx, redisKey)
+}
+
+func TestCodecRaw(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	redisKey := "gotest:redis_util:codec_raw"
+
+	// 单次调用覆盖默认 codec
+	result := ""
+	err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+		Key: redisKey, ExpireSeconds: 600, Result: &result, Codec: &RawCodec{},
+		FallbackFunc: func() (interface{}, error) {
+			return "raw value", nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "raw value", result)
+
+	var rawValue string
+
+	err = redisUtil.WrapDo(ctx, func(con redis.Conn) error {
+		rawValue, err = redis.String(con.Do("GET", redisKey))
+
+		return err
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "raw value", rawValue)
+
+	_, err = (&RawCodec{}).Encode(123.4)
+	assert.True(t, errors.Is(err, ErrRawCodecType))
+
+	_ = redisUtil.Del(ctx, redisKey)
+}
+
+func TestCodecNum(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionCodec(&JSONCodec{}))
+	keys := []string{"gotest:redis_util:codec_num1", "gotest:redis_util:codec_num2"}
+
+	// 整数不经过 codec, 可以继续使用 INCR
+	result, err := redisUtil.BatchSet(ctx, &BatchSetParams{
+		Keys: keys, Values: []interface{}{1, 2}, ExpireSecondsSlice: []int{600, 600},
+		Codec: &GobCodec{},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, keys, result.SuccessKeys())
+
+	value, err := redisUtil.Incr(ctx, keys[0])
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), value)
+
+	mgetResult := make([]int, len(keys))
+	hits, err := redisUtil.MGet(ctx, keys, &mgetResult)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, true}, hits)
+	assert.Equal(t, []int{2, 2}, mgetResult)
+
+	for _, key := range keys {
+		_ = redisUtil.Del(ctx, key)
+	}
+}
+
+func TestCodecLegacyGobNum(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	redisKey := "gotest:redis_util:codec_legacy_gob_num"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, redisKey)
+	}()
+
+	// 旧版本 BatchSet 写入的 gob 编码的整数
+	data, err := (&GobCodec{}).Encode(123)
+	assert.Equal(t, nil, err)
+
+	err = redisUtil.WrapDo(ctx, func(con redis.Conn) error {
+		_, err = con.Do("SET", redisKey, data, "EX", 600)
+
+		return err
+	})
+	assert.Equal(t, nil, err)
+
+	result := 0
+	hit, err := redisUtil.Get(ctx, redisKey, &result)
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, 123, result)
+
+	mgetResult := make([]int64, 1)
+	hits, err := redisUtil.MGet(ctx, []string{redisKey}, &mgetResult)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true}, hits)
+	assert.Equal(t, int64(123), mgetResult[0])
+}
@@ -1,28 +1,185 @@
 package redisutil
 
 import (
+	"context"
+	"math/rand"
 	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/gomodule/redigo/redis"
 	"github.com/pkg/errors"
 )
 
 var ErrInvalidNum = errors.New("Invalid num")
 
+var ErrNoKeyNamespace = errors.New("key namespace is not set")
+
 // 为了以后升级可以统一让所有key失效
-func keyPatch(key string) string {
-	return key
+// 设置了 OptionKeyNamespace 时 key 会加上 "namespace:v{version}:" 前缀, 升级 version 即可让所有key失效
+func (ru *RedisUtil) keyPatch(key string) string {
+	if ru.keyNamespace == "" {
+		return key
+	}
+
+	return ru.keyNamespace + ":v" + strconv.FormatInt(ru.KeyVersion(), 10) + ":" + key
 }
 
-func keysPatch(keys []string) []interface{} {
+func (ru *RedisUtil) keysPatch(keys []string) []interface{} {
 	result := make([]interface{}, len(keys))
 	for i, key := range keys {
-		result[i] = keyPatch(key)
+		result[i] = ru.keyPatch(key)
 	}
 
 	return result
 }
 
+func (ru *RedisUtil) KeyVersion() int64 {
+	return atomic.LoadInt64(&ru.keyVersion)
+}
+
+// SetKeyVersion 只修改当前进程的 key 版本
+func (ru *RedisUtil) SetKeyVersion(version int64) {
+	atomic.StoreInt64(&ru.keyVersion, version)
+}
+
+// BumpKeyVersion 在 redis 中升级 key 版本并更新当前进程的版本
+// 其他进程每 OptionKeyVersionSync 的间隔自动同步, 开启 OptionInvalidation 时通过 pub/sub 立即同步
+// redis 中没有版本时以当前进程的版本为基础升级
+func (ru *RedisUtil) BumpKeyVersion(ctx context.Context) (version int64, err error) {
+	if ru.keyNamespace == "" {
+		return 0, ErrNoKeyNamespace
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		if _, err = conDo(ctx, con, "SETNX", ru.keyVersionKey(), ru.KeyVersion()); err != nil {
+			return errors.WithStack(err)
+		}
+
+		version, err = redis.Int64(conDo(ctx, con, "INCR", ru.keyVersionKey()))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	ru.SetKeyVersion(version)
+	ru.publishKeyVersion(ctx, version)
+
+	return version, nil
+}
+
+// SyncKeyVersion 从 redis 同步 key 版本, redis 中没有版本时保持当前版本不变
+func (ru *RedisUtil) SyncKeyVersion(ctx context.Context) (version int64, err error) {
+	if ru.keyNamespace == "" {
+		return 0, ErrNoKeyNamespace
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		version, err = redis.Int64(conDo(ctx, con, "GET", ru.keyVersionKey()))
+
+		return err
+	})
+
+	if err == redis.ErrNil {
+		return ru.KeyVersion(), nil
+	}
+
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	ru.SetKeyVersion(version)
+
+	return version, nil
+}
+
+// 版本 key 本身不带版本前缀
+func (ru *RedisUtil) keyVersionKey() string {
+	return ru.keyNamespace + ":keyversion"
+}
+
+// 只升级不降级, 避免乱序的同步结果把版本改回旧值
+func (ru *RedisUtil) advanceKeyVersion(version int64) {
+	for {
+		current := ru.KeyVersion()
+		if version <= current || atomic.CompareAndSwapInt64(&ru.keyVersion, current, version) {
+			return
+		}
+	}
+}
+
+// 从 redis 读取版本, redis 中的版本更大时更新当前进程的版本
+func (ru *RedisUtil) refreshKeyVersion(ctx context.Context) error {
+	var version int64
+
+	err := ru.WrapDo(ctx, func(con redis.Conn) error {
+		var err error
+
+		version, err = redis.Int64(conDo(ctx, con, "GET", ru.keyVersionKey()))
+
+		return err
+	})
+
+	if err == redis.ErrNil {
+		return nil
+	}
+
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	ru.advanceKeyVersion(version)
+
+	return nil
+}
+
+// 创建时同步一次 key 版本, 开启 OptionKeyVersionSync 时之后在后台定期同步, 直到 Close
+func (ru *RedisUtil) startKeyVersionSync() {
+	if ru.keyNamespace == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyVersionSyncTimeout)
+	if err := ru.refreshKeyVersion(ctx); err != nil {
+		ru.getLogger().Errorf(ctx, "CacheUtil.startKeyVersionSync, error:%+v", err)
+	}
+
+	cancel()
+
+	if ru.keyVersionSyncInterval <= 0 {
+		return
+	}
+
+	ru.closeWG.Add(1)
+
+	go func() {
+		defer ru.closeWG.Done()
+
+		ticker := time.NewTicker(ru.keyVersionSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ru.closeCh:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), keyVersionSyncTimeout)
+			if err := ru.refreshKeyVersion(ctx); err != nil {
+				ru.getLogger().Errorf(ctx, "CacheUtil.refreshKeyVersion, error:%+v", err)
+			}
+
+			cancel()
+		}
+	}()
+}
+
 func isNum(i interface{}) (string, bool) {
 	switch vi := i.(type) {
 	case int8:
@@ -127,3 +284,58 @@ func bytesToNum(b []byte, num interface{}) error {
 
 	return nil
 }
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// detachedContext 用于后台任务, 保留 ctx 中的值, 不继承超时和取消
+type detachedContext struct {
+	parent context.Context
+}
+
+func detachContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (c detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// lockedRand 并发安全的随机数
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rand: rand.New(rand.NewSource(seed))} //nolint:gosec
+}
+
+// 取值 (0, 1]
+func (lr *lockedRand) float64() float64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return 1 - lr.rand.Float64()
+}
+
+// 取值 [0, n)
+func (lr *lockedRand) intn(n int) int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return lr.rand.Intn(n)
+}
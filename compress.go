@@ -0,0 +1,174 @@
+package redisutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Compressor 缓存值压缩, 通过 OptionCompress 开启, 可以实现 snappy, zstd 等自定义压缩
+type Compressor interface {
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+const (
+	CompressorNameGzip = "gzip"
+	CompressorNameZlib = "zlib"
+)
+
+// 压缩算法 id 记录在信封 flags 的高4位, 取值 1-15
+const (
+	CompressorIDGzip uint8 = 1
+	CompressorIDZlib uint8 = 2
+
+	maxCompressorID uint8 = 15
+)
+
+// MaxDecompressedSize 解压后的最大长度, 和 redis 字符串的最大长度一致, 避免损坏或恶意的数据无限制地分配内存
+const MaxDecompressedSize = 512 << 20
+
+var (
+	ErrCompressorNotRegistered = errors.New("compressor is not registered")
+	ErrDecompressedTooLarge    = errors.New("decompressed data is too large")
+)
+
+type compressorRegistry struct {
+	sync.RWMutex
+
+	ids         map[string]uint8 // compressor name => id
+	compressors map[uint8]Compressor
+}
+
+var defaultCompressorRegistry = &compressorRegistry{
+	ids: map[string]uint8{
+		CompressorNameGzip: CompressorIDGzip, CompressorNameZlib: CompressorIDZlib,
+	},
+	compressors: map[uint8]Compressor{
+		CompressorIDGzip: &GzipCompressor{}, CompressorIDZlib: &ZlibCompressor{},
+	},
+}
+
+// RegisterCompressor 注册自定义压缩算法, id 取值 1-15, 所有读写进程需要注册相同的 id
+func RegisterCompressor(id uint8, compressor Compressor) error {
+	if id < 1 || id > maxCompressorID {
+		return errors.New(fmt.Sprintf("compressor id must between 1 and %d", maxCompressorID))
+	}
+
+	defaultCompressorRegistry.Lock()
+	defer defaultCompressorRegistry.Unlock()
+
+	defaultCompressorRegistry.ids[compressor.Name()] = id
+	defaultCompressorRegistry.compressors[id] = compressor
+
+	return nil
+}
+
+func compressorID(compressor Compressor) (uint8, error) {
+	defaultCompressorRegistry.RLock()
+	defer defaultCompressorRegistry.RUnlock()
+
+	id, ok := defaultCompressorRegistry.ids[compressor.Name()]
+	if !ok {
+		return 0, errors.WithMessage(ErrCompressorNotRegistered, compressor.Name())
+	}
+
+	return id, nil
+}
+
+func compressorByID(id uint8) (Compressor, error) {
+	defaultCompressorRegistry.RLock()
+	defer defaultCompressorRegistry.RUnlock()
+
+	compressor, ok := defaultCompressorRegistry.compressors[id]
+	if !ok {
+		return nil, errors.WithMessage(ErrCompressorNotRegistered, fmt.Sprintf("compressor id:%d", id))
+	}
+
+	return compressor, nil
+}
+
+type GzipCompressor struct{}
+
+func (c *GzipCompressor) Name() string {
+	return CompressorNameGzip
+}
+
+func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	writer := gzip.NewWriter(&b)
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return b.Bytes(), nil
+}
+
+func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer reader.Close()
+
+	return readAllLimited(reader)
+}
+
+type ZlibCompressor struct{}
+
+func (c *ZlibCompressor) Name() string {
+	return CompressorNameZlib
+}
+
+func (c *ZlibCompressor) Compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	writer := zlib.NewWriter(&b)
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return b.Bytes(), nil
+}
+
+func (c *ZlibCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer reader.Close()
+
+	return readAllLimited(reader)
+}
+
+// 最多读取 MaxDecompressedSize, 超过时返回 ErrDecompressedTooLarge
+func readAllLimited(reader io.Reader) ([]byte, error) {
+	result, err := ioutil.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(result) > MaxDecompressedSize {
+		return nil, errors.WithStack(ErrDecompressedTooLarge)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	ctx := context.Background()
+
+	keys := []string{"gotest:redis_util:compress_small", "gotest:redis_util:compress_large"}
+
+	type valueStruct struct {
+		Name string
+	}
+
+	smallData := &valueStruct{Name: "small"}
+	largeData := &valueStruct{Name: strings.Repeat("large", 1000)}
+
+	for _, compressor := range []Compressor{&GzipCompressor{}, &ZlibCompressor{}} {
+		redisUtil := NewRedisUtil(getTestPool(), OptionCodec(&JSONCodec{}), OptionCompress(compressor, 1024))
+
+		_, err := redisUtil.BatchSet(ctx, &BatchSetParams{
+			Keys: keys, Values: []interface{}{smallData, largeData}, ExpireSecondsSlice: []int{600, 600},
+		})
+		assert.Equal(t, nil, err)
+
+		rawValues := make([][]byte, 0)
+
+		err = redisUtil.WrapDo(ctx, func(con redis.Conn) error {
+			rawValues, err = redis.ByteSlices(con.Do("MGET", keys[0], keys[1]))
+
+			return err
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, `{"Name":"small"}`, string(rawValues[0])) // 没有超过阈值不压缩
+		assert.True(t, isEnvelope(rawValues[1]))
+		assert.True(t, len(rawValues[1]) < 1024)
+
+		// 没有开启压缩的进程也可以读取
+		mgetResult := make([]*valueStruct, len(keys))
+		hits, err := NewRedisUtil(getTestPool(), OptionCodec(&JSONCodec{})).MGet(ctx, keys, &mgetResult)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []bool{true, true}, hits)
+		assert.Equal(t, smallData, mgetResult[0])
+		assert.Equal(t, largeData, mgetResult[1])
+
+		result := &valueStruct{}
+		err = redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: keys[1], ExpireSeconds: 600, Result: &result, FlushCache: true,
+			FallbackFunc: func() (interface{}, error) {
+				return largeData, nil
+			},
+		})
+		assert.Equal(t, nil, err)
+
+		result = &valueStruct{}
+		hit, err := redisUtil.Get(ctx, keys[1], result)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, hit)
+		assert.Equal(t, largeData, result)
+
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}
+}
@@ -1,24 +1,111 @@
-//nolint:unparam
 package redisutil
 
 import (
 	"context"
+	"net"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
 )
 
+var (
+	ErrTimeout  = errors.New("redis command timeout")  // ctx 超时 或 redis 读写超时
+	ErrCanceled = errors.New("redis command canceled") // ctx 被取消
+)
+
+// IsTimeout 判断是否是 redis 超时的错误
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// IsCanceled 判断是否是 ctx 取消导致的错误
+func IsCanceled(err error) bool {
+	return errors.Is(err, ErrCanceled)
+}
+
 func conDo(ctx context.Context, con redis.Conn, commandName string, args ...interface{}) (interface{}, error) {
-	return con.Do(commandName, args...)
+	if err := ctx.Err(); err != nil {
+		return nil, ctxError(ctx, err)
+	}
+
+	var (
+		reply interface{}
+		err   error
+	)
+
+	if conCtx, ok := con.(redis.ConnWithContext); ok {
+		reply, err = conCtx.DoContext(ctx, commandName, args...)
+	} else {
+		reply, err = con.Do(commandName, args...)
+	}
+
+	return reply, ctxError(ctx, err)
 }
 
 func conSend(ctx context.Context, con redis.Conn, commandName string, args ...interface{}) error {
-	return con.Send(commandName, args...)
+	if err := ctx.Err(); err != nil {
+		return ctxError(ctx, err)
+	}
+
+	return ctxError(ctx, con.Send(commandName, args...))
 }
 
 func conFlush(ctx context.Context, con redis.Conn) error {
-	return con.Flush()
+	if err := ctx.Err(); err != nil {
+		return ctxError(ctx, err)
+	}
+
+	return ctxError(ctx, con.Flush())
 }
 
 func conReceive(ctx context.Context, con redis.Conn) (interface{}, error) {
-	return con.Receive()
+	if err := ctx.Err(); err != nil {
+		return nil, ctxError(ctx, err)
+	}
+
+	var (
+		reply interface{}
+		err   error
+	)
+
+	if conCtx, ok := con.(redis.ConnWithContext); ok {
+		reply, err = conCtx.ReceiveContext(ctx)
+	} else {
+		reply, err = con.Receive()
+	}
+
+	return reply, ctxError(ctx, err)
+}
+
+// ctxError 把超时和取消类的错误转换为 ErrTimeout, ErrCanceled, 其他错误原样返回
+func ctxError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrCanceled) {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) ||
+		(errors.Is(ctx.Err(), context.Canceled) && !isRedisReplyError(err)) {
+		return errors.WithMessage(ErrCanceled, err.Error())
+	}
+
+	var netErr net.Error
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		(errors.Is(ctx.Err(), context.DeadlineExceeded) && !isRedisReplyError(err)) {
+		return errors.WithMessage(ErrTimeout, err.Error())
+	}
+
+	return err
+}
+
+// redis 服务端返回的错误, 和超时无关
+func isRedisReplyError(err error) bool {
+	var replyErr redis.Error
+
+	return errors.As(err, &replyErr)
 }
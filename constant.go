@@ -1,9 +1,21 @@
 package redisutil
 
+import (
+	"context"
+	"time"
+)
+
 const (
 	DefaultSingleFlightGroupNum = 10
 
+	DefaultDistributedLockTTL  = 5 * time.Second
+	DefaultDistributedLockWait = 3 * time.Second
+
 	TTLNoExpire = -1 // 不过期
+
+	keyVersionSyncTimeout = time.Second
+
+	DefaultNotFoundExpireSeconds = 60 // 不存在的标记默认过期时间
 )
 
 type FallbackFunc func() (interface{}, error)
@@ -11,3 +23,6 @@ type FallbackFunc func() (interface{}, error)
 type MgetFallbackFunc func(fallbackIndex int) (interface{}, error)
 
 type MgetBatchFallbackFunc func(fallbackIndexes []int) (map[int]interface{}, error)
+
+// CacheWrapperMget 批量回写缓存部分失败时的回调
+type BatchSetFailedHook func(ctx context.Context, result *BatchSetResult)
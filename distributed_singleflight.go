@@ -0,0 +1,158 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const distributedPollInterval = 50 * time.Millisecond // 等待锁时轮询缓存的间隔
+
+// 跨进程的 singleflight, 抢到锁的进程调用 fallback 并写入缓存
+// 其他进程轮询 tryGet 等待缓存写入, 超过最长等待时间或 redis 异常时直接调用 fallback
+// tryGet 命中不存在的标记时返回 ErrNotFound
+func (ru *RedisUtil) distributedDo(ctx context.Context, key string,
+	tryGet func() (interface{}, bool, error), fallback func() (interface{}, error)) (interface{}, error) {
+	lockKey := "lock:" + key
+	token := newLockToken()
+	deadline := time.Now().Add(ru.getDistributedLockWait())
+
+	for waited := false; ; waited = true {
+		locked, err := ru.acquireLock(ctx, lockKey, token, ru.getDistributedLockTTL())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			ru.getLogger().Errorf(ctx, "CacheUtil.distributedDo, key:%s, acquire lock error:%+v", key, err)
+
+			return fallback()
+		}
+
+		if locked {
+			return ru.distributedLockedCall(ctx, key, lockKey, token, waited, tryGet, fallback)
+		}
+
+		timer := time.NewTimer(distributedPollInterval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctxError(ctx, ctx.Err())
+		case <-timer.C:
+		}
+
+		if data, ok, err := tryGet(); ok {
+			return data, err
+		}
+
+		if time.Now().After(deadline) {
+			ru.getLogger().Errorf(ctx, "CacheUtil.distributedDo, key:%s, wait lock timeout, call fallback directly", key)
+
+			return fallback()
+		}
+	}
+}
+
+// 持有锁时调用 fallback, 结束后释放锁
+func (ru *RedisUtil) distributedLockedCall(ctx context.Context, key, lockKey, token string, waited bool,
+	tryGet func() (interface{}, bool, error), fallback func() (interface{}, error)) (interface{}, error) {
+	defer func() {
+		if _, err := ru.releaseLock(detachContext(ctx), lockKey, token); err != nil {
+			ru.getLogger().Errorf(ctx, "CacheUtil.distributedDo, key:%s, release lock error:%+v", key, err)
+		}
+	}()
+
+	if waited { // 等待期间上一个持有者可能已经写入缓存
+		if data, ok, err := tryGet(); ok {
+			return data, err
+		}
+	}
+
+	return fallback()
+}
+
+// 批量获取的锁 key, 排序后取 sha1, 长度不随 key 的数量增长, 相同的 key 集合顺序不同时也使用同一个锁
+func batchLockKey(keys []string) string {
+	sortedKeys := make([]string, len(keys))
+	copy(sortedKeys, keys)
+	sort.Strings(sortedKeys)
+
+	hash := sha1.New() //nolint:gosec
+	for _, key := range sortedKeys {
+		_, _ = hash.Write([]byte(key))
+		_, _ = hash.Write([]byte{0})
+	}
+
+	return "batch:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// 从缓存读取一个 valueType 类型的值
+func (ru *RedisUtil) tryGetValue(ctx context.Context,
+	key string, valueType reflect.Type, vc *valueCodec) (interface{}, bool, error) {
+	valuePtr := reflect.New(valueType)
+
+	hit, meta, err := ru.tieredGet(ctx, key, valuePtr.Interface(), vc)
+	if err != nil || !hit || meta.expired() {
+		return nil, false, nil
+	}
+
+	if meta.notFound {
+		return nil, true, ErrNotFound
+	}
+
+	return valuePtr.Elem().Interface(), true, nil
+}
+
+// 从缓存批量读取 indexes 对应的 key, 全部命中时才返回 true, 不存在的标记对应的值为 ErrNotFound
+func (ru *RedisUtil) tryMGetValues(ctx context.Context, keys []string,
+	indexes []int, valueType reflect.Type, vc *valueCodec) (interface{}, bool, error) {
+	getKeys := make([]string, len(indexes))
+
+	for j, index := range indexes {
+		getKeys[j] = keys[index]
+	}
+
+	valuesPtr := reflect.New(reflect.SliceOf(valueType))
+	valuesPtr.Elem().Set(reflect.MakeSlice(reflect.SliceOf(valueType), len(indexes), len(indexes)))
+
+	hits, metas, err := ru.tieredMGet(ctx, getKeys, valuesPtr.Interface(), vc)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	result := make(map[int]interface{}, len(indexes))
+
+	for j, index := range indexes {
+		if !hits[j] || metas[j].expired() {
+			return nil, false, nil
+		}
+
+		if metas[j].notFound {
+			result[index] = ErrNotFound
+		} else {
+			result[index] = valuesPtr.Elem().Index(j).Interface()
+		}
+	}
+
+	return result, true, nil
+}
+
+func (ru *RedisUtil) getDistributedLockTTL() time.Duration {
+	if ru.distributedLockTTL > 0 {
+		return ru.distributedLockTTL
+	}
+
+	return DefaultDistributedLockTTL
+}
+
+func (ru *RedisUtil) getDistributedLockWait() time.Duration {
+	if ru.distributedLockWait > 0 {
+		return ru.distributedLockWait
+	}
+
+	return DefaultDistributedLockWait
+}
@@ -0,0 +1,189 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:lock"
+
+	locked, err := redisUtil.acquireLock(ctx, key, "token1", time.Second)
+	assert.Equal(t, nil, err)
+	assert.True(t, locked)
+
+	locked, err = redisUtil.acquireLock(ctx, key, "token2", time.Second)
+	assert.Equal(t, nil, err)
+	assert.False(t, locked)
+
+	// 非持有者不能释放
+	released, err := redisUtil.releaseLock(ctx, key, "token2")
+	assert.Equal(t, nil, err)
+	assert.False(t, released)
+
+	released, err = redisUtil.releaseLock(ctx, key, "token1")
+	assert.Equal(t, nil, err)
+	assert.True(t, released)
+
+	locked, err = redisUtil.acquireLock(ctx, key, "token2", time.Second)
+	assert.Equal(t, nil, err)
+	assert.True(t, locked)
+
+	_, _ = redisUtil.releaseLock(ctx, key, "token2")
+}
+
+func TestCacheWrapperDistributedSingleFlight(t *testing.T) {
+	ctx := context.Background()
+
+	key := "gotest:redis_wrapper:distributed_singleflight"
+
+	// 多个 RedisUtil 模拟多个进程, 不开启进程内 singleflight
+	redisUtils := make([]*RedisUtil, 5)
+	for i := range redisUtils {
+		redisUtils[i] = NewRedisUtil(getTestPool())
+	}
+
+	defer func() {
+		_ = redisUtils[0].Del(ctx, key)
+	}()
+
+	var fallbackCount int32
+
+	fallbackFunc := func() (interface{}, error) {
+		n := atomic.AddInt32(&fallbackCount, 1)
+		time.Sleep(time.Millisecond * 300)
+
+		return fmt.Sprintf("value%d", n), nil
+	}
+
+	results := make([]string, len(redisUtils))
+	goGroup, _ := errgroup.WithContext(ctx)
+
+	for i, redisUtil := range redisUtils {
+		i, redisUtil := i, redisUtil
+
+		goGroup.Go(func() error {
+			return redisUtil.CacheWrapper(ctx, &WrapperParams{
+				Key: key, ExpireSeconds: 600, Result: &results[i],
+				FallbackFunc: fallbackFunc, DistributedSingleFlight: true,
+			})
+		})
+	}
+
+	assert.Equal(t, nil, goGroup.Wait())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fallbackCount))
+
+	for _, result := range results {
+		assert.Equal(t, "value1", result)
+	}
+}
+
+func TestCacheWrapperDistributedSingleFlightTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionDistributedSingleFlight(time.Second, time.Millisecond*200))
+	key := "gotest:redis_wrapper:distributed_singleflight_timeout"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	// 锁被其他进程持有且一直没有写入缓存
+	locked, err := redisUtil.acquireLock(ctx, "lock:"+key, "other", time.Second*5)
+	assert.Equal(t, nil, err)
+	assert.True(t, locked)
+
+	defer func() {
+		_, _ = redisUtil.releaseLock(ctx, "lock:"+key, "other")
+	}()
+
+	start := time.Now()
+	result := ""
+
+	err = redisUtil.CacheWrapper(ctx, &WrapperParams{
+		Key: key, ExpireSeconds: 600, Result: &result, DistributedSingleFlight: true,
+		FallbackFunc: func() (interface{}, error) {
+			return "value", nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+	assert.True(t, time.Since(start) >= time.Millisecond*200)
+}
+
+func TestCacheWrapperMgetDistributedSingleFlight(t *testing.T) {
+	ctx := context.Background()
+
+	keys := []string{"gotest:redis_wrapper:distributed_mget1", "gotest:redis_wrapper:distributed_mget2"}
+
+	redisUtils := make([]*RedisUtil, 5)
+	for i := range redisUtils {
+		redisUtils[i] = NewRedisUtil(getTestPool())
+	}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtils[0].Del(ctx, key)
+		}
+	}()
+
+	var batchFallbackCount int32
+
+	batchFallbackFunc := func(fallbackIndexes []int) (map[int]interface{}, error) {
+		atomic.AddInt32(&batchFallbackCount, 1)
+		time.Sleep(time.Millisecond * 300)
+
+		result := make(map[int]interface{})
+		for _, i := range fallbackIndexes {
+			result[i] = fmt.Sprintf("value%d", i)
+		}
+
+		return result, nil
+	}
+
+	results := make([][]string, len(redisUtils))
+	goGroup, _ := errgroup.WithContext(ctx)
+
+	for i, redisUtil := range redisUtils {
+		i, redisUtil := i, redisUtil
+		results[i] = make([]string, len(keys))
+
+		goGroup.Go(func() error {
+			return redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+				Keys: keys, ExpireSeconds: []int{600, 600}, ResultSlice: &results[i],
+				BatchFallbackFunc: batchFallbackFunc, DistributedSingleFlight: true,
+			})
+		})
+	}
+
+	assert.Equal(t, nil, goGroup.Wait())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&batchFallbackCount))
+
+	for _, result := range results {
+		assert.Equal(t, []string{"value0", "value1"}, result)
+	}
+}
+
+func TestBatchLockKey(t *testing.T) {
+	keys := make([]string, 5000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("gotest:redis_wrapper:batch_lock_key:%d", i)
+	}
+
+	lockKey := batchLockKey(keys)
+	assert.Equal(t, len("batch:")+40, len(lockKey))
+
+	// 顺序不同的相同 key 集合使用同一个锁
+	assert.Equal(t, batchLockKey([]string{"a", "b"}), batchLockKey([]string{"b", "a"}))
+	assert.NotEqual(t, batchLockKey([]string{"a", "b"}), batchLockKey([]string{"ab"}))
+}
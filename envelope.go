@@ -0,0 +1,274 @@
+package redisutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 缓存值信封格式, 用于自描述编码方式和结构版本:
+// magic(2 byte) | codec id(1 byte) | flags(1 byte) | schema version(4 byte, big endian) | [meta] | payload
+// meta 由 flags 标记是否存在: meta 长度(uvarint) | 多个 tag(1 byte) + value(varint), 不认识的 tag 直接跳过
+// 0xC1 不会出现在 utf-8 文本和 gob 编码的开头, 旧的没有信封的数据可以正常读取
+// 没有开启信封时, 编码结果以 magic 开头的值(RawCodec 的二进制数据)也会带信封写入, 保证可以原样读取
+const (
+	envelopeMagic0    byte = 0xC1
+	envelopeMagic1    byte = 0xE5
+	envelopeHeaderLen      = 8
+)
+
+// flags: 低4位为标记位, 高4位为压缩算法 id
+const (
+	envelopeFlagCompressed uint8 = 1 << 0
+	envelopeFlagMeta       uint8 = 1 << 1
+
+	envelopeFlagMask         uint8 = 0x0F
+	envelopeKnownFlags       uint8 = envelopeFlagCompressed | envelopeFlagMeta
+	envelopeCompressorIDBits       = 4
+)
+
+const (
+	CodecIDGob  uint8 = 1
+	CodecIDJSON uint8 = 2
+	CodecIDRaw  uint8 = 3
+)
+
+var (
+	ErrCodecNotRegistered = errors.New("codec is not registered")
+	ErrInvalidEnvelope    = errors.New("invalid envelope")
+)
+
+type codecRegistry struct {
+	sync.RWMutex
+
+	ids    map[string]uint8 // codec name => id
+	codecs map[uint8]Codec  // id => codec
+}
+
+var defaultCodecRegistry = &codecRegistry{
+	ids: map[string]uint8{
+		CodecNameGob: CodecIDGob, CodecNameJSON: CodecIDJSON, CodecNameRaw: CodecIDRaw,
+	},
+	codecs: map[uint8]Codec{
+		CodecIDGob: &GobCodec{}, CodecIDJSON: &JSONCodec{}, CodecIDRaw: &RawCodec{},
+	},
+}
+
+// RegisterCodec 注册自定义 codec, 信封中通过 id 记录编码方式, 所有读写进程需要注册相同的 id
+func RegisterCodec(id uint8, codec Codec) {
+	defaultCodecRegistry.Lock()
+	defer defaultCodecRegistry.Unlock()
+
+	defaultCodecRegistry.ids[codec.Name()] = id
+	defaultCodecRegistry.codecs[id] = codec
+}
+
+func codecID(codec Codec) (uint8, error) {
+	defaultCodecRegistry.RLock()
+	defer defaultCodecRegistry.RUnlock()
+
+	id, ok := defaultCodecRegistry.ids[codec.Name()]
+	if !ok {
+		return 0, errors.WithMessage(ErrCodecNotRegistered, codec.Name())
+	}
+
+	return id, nil
+}
+
+func codecByID(id uint8) (Codec, error) {
+	defaultCodecRegistry.RLock()
+	defer defaultCodecRegistry.RUnlock()
+
+	codec, ok := defaultCodecRegistry.codecs[id]
+	if !ok {
+		return nil, errors.WithMessage(ErrCodecNotRegistered, fmt.Sprintf("codec id:%d", id))
+	}
+
+	return codec, nil
+}
+
+// meta 中的字段
+const (
+	metaTagSoftExpireAt uint8 = 1
+	metaTagExpireAt     uint8 = 2
+	metaTagComputeCost  uint8 = 3
+	metaTagNotFound     uint8 = 4
+)
+
+// valueMeta 和缓存值一起存储的附加信息, 时间都是 unix 毫秒, 0 表示没有
+type valueMeta struct {
+	softExpireAt int64 // 逻辑过期时间
+	expireAt     int64 // 逻辑过期时间, 没有开启 stale-if-error 时和 redis 中的过期时间一致
+	computeCost  int64 // fallback 计算耗时, 毫秒
+	notFound     bool  // 不存在的标记(tombstone), 没有 payload
+}
+
+func (m *valueMeta) softExpired() bool {
+	return m != nil && m.softExpireAt > 0 && nowMillis() >= m.softExpireAt
+}
+
+// 逻辑过期, 开启 stale-if-error 时 redis 中的过期时间比 expireAt 更长
+func (m *valueMeta) expired() bool {
+	return m != nil && m.expireAt > 0 && nowMillis() >= m.expireAt
+}
+
+// XFetch 概率提前过期: now - computeCost * beta * ln(random) >= expireAt, random 取值 (0, 1]
+// 越接近过期时间, 计算耗时越长, 提前过期的概率越大
+// 没有开启时不调用 random, 避免在读缓存的路径上竞争随机数的锁
+func (m *valueMeta) earlyExpired(beta float64, random func() float64) bool {
+	if m == nil || m.expireAt <= 0 || m.computeCost <= 0 || beta <= 0 {
+		return false
+	}
+
+	return float64(nowMillis())-float64(m.computeCost)*beta*math.Log(random()) >= float64(m.expireAt)
+}
+
+func (m *valueMeta) marshal() []byte {
+	fields := make([]byte, 0, binary.MaxVarintLen64+1)
+
+	var notFound int64
+	if m.notFound {
+		notFound = 1
+	}
+
+	for _, field := range []struct {
+		tag   uint8
+		value int64
+	}{
+		{metaTagSoftExpireAt, m.softExpireAt},
+		{metaTagExpireAt, m.expireAt},
+		{metaTagComputeCost, m.computeCost},
+		{metaTagNotFound, notFound},
+	} {
+		if field.value > 0 {
+			fields = append(fields, field.tag)
+			fields = appendVarint(fields, field.value)
+		}
+	}
+
+	result := make([]byte, 0, len(fields)+binary.MaxVarintLen64)
+	result = appendUvarint(result, uint64(len(fields)))
+
+	return append(result, fields...)
+}
+
+// 返回 meta 之后剩余的数据
+func unmarshalValueMeta(data []byte) (*valueMeta, []byte, error) {
+	metaLen, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < metaLen {
+		return nil, nil, errors.WithMessage(ErrInvalidEnvelope, "invalid meta length")
+	}
+
+	fields := data[n : n+int(metaLen)]
+	result := &valueMeta{}
+
+	for len(fields) > 0 {
+		tag := fields[0]
+
+		value, m := binary.Varint(fields[1:])
+		if m <= 0 {
+			return nil, nil, errors.WithMessage(ErrInvalidEnvelope, fmt.Sprintf("invalid meta tag:%d", tag))
+		}
+
+		switch tag {
+		case metaTagSoftExpireAt:
+			result.softExpireAt = value
+		case metaTagExpireAt:
+			result.expireAt = value
+		case metaTagComputeCost:
+			result.computeCost = value
+		case metaTagNotFound:
+			result.notFound = value > 0
+		}
+
+		fields = fields[1+m:]
+	}
+
+	return result, data[n+int(metaLen):], nil
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	return append(b, buf[:binary.PutVarint(buf, v)]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+type envelope struct {
+	codecID       uint8
+	flags         uint8
+	schemaVersion uint32
+	meta          *valueMeta
+	payload       []byte
+}
+
+func (e *envelope) marshal() []byte {
+	var metaData []byte
+
+	if e.meta != nil {
+		e.flags |= envelopeFlagMeta
+		metaData = e.meta.marshal()
+	}
+
+	result := make([]byte, envelopeHeaderLen, envelopeHeaderLen+len(metaData)+len(e.payload))
+	result[0] = envelopeMagic0
+	result[1] = envelopeMagic1
+	result[2] = e.codecID
+	result[3] = e.flags
+	binary.BigEndian.PutUint32(result[4:envelopeHeaderLen], e.schemaVersion)
+	result = append(result, metaData...)
+
+	return append(result, e.payload...)
+}
+
+func isEnvelope(data []byte) bool {
+	return len(data) >= envelopeHeaderLen &&
+		data[0] == envelopeMagic0 && data[1] == envelopeMagic1
+}
+
+func unmarshalEnvelope(data []byte) (*envelope, error) {
+	if !isEnvelope(data) {
+		return nil, ErrInvalidEnvelope
+	}
+
+	result := &envelope{
+		codecID:       data[2],
+		flags:         data[3],
+		schemaVersion: binary.BigEndian.Uint32(data[4:envelopeHeaderLen]),
+		payload:       data[envelopeHeaderLen:],
+	}
+
+	if result.flags&envelopeFlagMask&^envelopeKnownFlags != 0 { // 更新版本写入的数据
+		return nil, errors.WithMessage(ErrInvalidEnvelope, fmt.Sprintf("unknown flags:%d", result.flags))
+	}
+
+	if result.flags&envelopeFlagMeta != 0 {
+		var err error
+
+		if result.meta, result.payload, err = unmarshalValueMeta(result.payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (e *envelope) compressed() bool {
+	return e.flags&envelopeFlagCompressed != 0
+}
+
+func (e *envelope) compressorID() uint8 {
+	return e.flags >> envelopeCompressorIDBits
+}
+
+func (e *envelope) setCompressed(compressorID uint8) {
+	e.flags = e.flags&envelopeFlagMask | envelopeFlagCompressed | compressorID<<envelopeCompressorIDBits
+}
@@ -0,0 +1,159 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeCodecMigration(t *testing.T) {
+	ctx := context.Background()
+
+	redisKey := "gotest:redis_util:envelope_codec"
+
+	type valueStruct struct {
+		Name string
+		Age  int
+	}
+
+	data := &valueStruct{Name: "TestEnvelope", Age: 18}
+
+	// 没有信封的旧数据
+	oldRedisUtil := NewRedisUtil(getTestPool())
+	err := oldRedisUtil.Set(ctx, redisKey, data, 600)
+	assert.Equal(t, nil, err)
+
+	jsonRedisUtil := NewRedisUtil(getTestPool(), OptionCodec(&JSONCodec{}), OptionEnvelope(1))
+
+	result := &valueStruct{}
+	hit, err := jsonRedisUtil.get(ctx, redisKey, result, jsonRedisUtil.getValueCodec(&GobCodec{}))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+	assert.Equal(t, data, result)
+
+	// json 写入, gob 配置的进程按信封中的 codec 读取
+	err = jsonRedisUtil.Set(ctx, redisKey, data, 600)
+	assert.Equal(t, nil, err)
+
+	gobRedisUtil := NewRedisUtil(getTestPool(), OptionEnvelope(1))
+
+	result = &valueStruct{}
+	hit, err = gobRedisUtil.Get(ctx, redisKey, result)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+	assert.Equal(t, data, result)
+
+	// 没有开启信封的进程也可以读取
+	result = &valueStruct{}
+	hit, err = oldRedisUtil.Get(ctx, redisKey, result)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+	assert.Equal(t, data, result)
+
+	_ = oldRedisUtil.Del(ctx, redisKey)
+}
+
+func TestEnvelopeSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+
+	keys := []string{"gotest:redis_util:envelope_schema1", "gotest:redis_util:envelope_schema2"}
+
+	type valueStruct struct {
+		Name string
+	}
+
+	redisUtilV1 := NewRedisUtil(getTestPool(), OptionEnvelope(1))
+	redisUtilV2 := NewRedisUtil(getTestPool(), OptionEnvelope(2))
+
+	_, err := redisUtilV1.BatchSet(ctx, &BatchSetParams{
+		Keys:               keys,
+		Values:             []interface{}{&valueStruct{Name: "v1"}, &valueStruct{Name: "v1"}},
+		ExpireSecondsSlice: []int{600, 600},
+	})
+	assert.Equal(t, nil, err)
+
+	// 版本不一致当作未命中
+	result := &valueStruct{}
+	hit, err := redisUtilV2.Get(ctx, keys[0], result)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, hit)
+
+	mgetResult := make([]*valueStruct, len(keys))
+	hits, err := redisUtilV2.MGet(ctx, keys, &mgetResult)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{false, false}, hits)
+
+	fallbackCount := 0
+
+	err = redisUtilV2.CacheWrapper(ctx, &WrapperParams{
+		Key: keys[0], ExpireSeconds: 600, Result: &result,
+		FallbackFunc: func() (interface{}, error) {
+			fallbackCount++
+
+			return &valueStruct{Name: "v2"}, nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, fallbackCount)
+	assert.Equal(t, "v2", result.Name)
+
+	hit, err = redisUtilV2.Get(ctx, keys[0], result)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+
+	for _, key := range keys {
+		_ = redisUtilV1.Del(ctx, key)
+	}
+}
+
+// 没有开启信封时, 以 magic 开头的二进制数据可以原样读取
+func TestEnvelopeMagicPrefixRaw(t *testing.T) {
+	ctx := context.Background()
+
+	redisKey := "gotest:redis_util:envelope_magic"
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionCodec(&RawCodec{}))
+
+	defer func() {
+		_ = redisUtil.Del(ctx, redisKey)
+	}()
+
+	data := []byte{envelopeMagic0, envelopeMagic1, 9, 0, 0, 0, 0, 0, 1, 2, 3}
+
+	err := redisUtil.Set(ctx, redisKey, data, 600)
+	assert.Equal(t, nil, err)
+
+	result := make([]byte, 0)
+	hit, err := redisUtil.Get(ctx, redisKey, &result)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+	assert.Equal(t, data, result)
+
+	// 不以 magic 开头的数据仍然原样写入
+	err = redisUtil.Set(ctx, redisKey, []byte("raw"), 600)
+	assert.Equal(t, nil, err)
+
+	stored := ""
+	_, _ = NewRedisUtil(getTestPool(), OptionCodec(&RawCodec{})).Get(ctx, redisKey, &stored)
+	assert.Equal(t, "raw", stored)
+}
+
+// 没有开启 XFetch 时不取随机数
+func TestValueMetaEarlyExpiredBeta(t *testing.T) {
+	meta := &valueMeta{expireAt: nowMillis() + 1000, computeCost: 100}
+
+	randomCalled := false
+	random := func() float64 {
+		randomCalled = true
+
+		return 1
+	}
+
+	assert.False(t, meta.earlyExpired(0, random))
+	assert.False(t, randomCalled)
+
+	assert.False(t, meta.earlyExpired(1, random))
+	assert.True(t, randomCalled)
+}
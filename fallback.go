@@ -0,0 +1,75 @@
+package redisutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrFallbackOverload = errors.New("too many fallbacks in flight") // 超过 OptionFallbackLimit 的限制
+	ErrFallbackTimeout  = errors.New("fallback timeout")             // 超过 FallbackTimeout
+)
+
+// IsOverload 判断是否是 fallback 并发超限被拒绝的错误
+func IsOverload(err error) bool {
+	return errors.Is(err, ErrFallbackOverload)
+}
+
+type fallbackResult struct {
+	data interface{}
+	err  error
+}
+
+// 在全局并发限制和超时内调用 fallback, 超时时间取 timeout 和 ctx 中较早的
+// 超时后 fallback 在后台继续执行完才释放并发名额, 结果丢弃
+func (ru *RedisUtil) callFallback(ctx context.Context,
+	timeout time.Duration, fallback func() (interface{}, error)) (interface{}, error) {
+	if ru.fallbackSem != nil {
+		select {
+		case ru.fallbackSem <- struct{}{}:
+		default:
+			return nil, errors.WithStack(ErrFallbackOverload)
+		}
+	}
+
+	release := func() {
+		if ru.fallbackSem != nil {
+			<-ru.fallbackSem
+		}
+	}
+
+	if timeout <= 0 && ctx.Done() == nil {
+		defer release()
+
+		return fallback()
+	}
+
+	resultCh := make(chan fallbackResult, 1)
+
+	go func() {
+		defer release()
+
+		data, err := fallback()
+		resultCh <- fallbackResult{data: data, err: err}
+	}()
+
+	var timeoutCh <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		timeoutCh = timer.C
+	}
+
+	select {
+	case result := <-resultCh:
+		return result.data, result.err
+	case <-timeoutCh:
+		return nil, errors.WithStack(ErrFallbackTimeout)
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctxError(ctx, ctx.Err()))
+	}
+}
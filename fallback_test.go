@@ -0,0 +1,150 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_wrapper:fallback_timeout"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	slowFunc := func() (interface{}, error) {
+		time.Sleep(time.Millisecond * 500)
+
+		return "value", nil
+	}
+
+	start := time.Now()
+	result := ""
+
+	err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+		Key: key, ExpireSeconds: 600, Result: &result,
+		FallbackFunc: slowFunc, FallbackTimeout: time.Millisecond * 100,
+	})
+	assert.True(t, errors.Is(err, ErrFallbackTimeout))
+	assert.True(t, time.Since(start) < time.Millisecond*300)
+
+	// 超时时间来自 ctx
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+	defer cancel()
+
+	err = redisUtil.CacheWrapper(timeoutCtx, &WrapperParams{
+		Key: key, ExpireSeconds: 600, Result: &result, FallbackFunc: slowFunc,
+	})
+	assert.True(t, IsTimeout(err))
+}
+
+func TestFallbackLimit(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionFallbackLimit(1))
+	keys := []string{"gotest:redis_wrapper:fallback_limit1", "gotest:redis_wrapper:fallback_limit2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		result := ""
+
+		done <- redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: keys[0], ExpireSeconds: 600, Result: &result,
+			FallbackFunc: func() (interface{}, error) {
+				close(started)
+				time.Sleep(time.Millisecond * 300)
+
+				return "value", nil
+			},
+		})
+	}()
+
+	<-started
+
+	// 并发名额已用完
+	result := ""
+	err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+		Key: keys[1], ExpireSeconds: 600, Result: &result,
+		FallbackFunc: func() (interface{}, error) {
+			return "value", nil
+		},
+	})
+	assert.True(t, IsOverload(err))
+
+	assert.Equal(t, nil, <-done)
+
+	err = redisUtil.CacheWrapper(ctx, &WrapperParams{
+		Key: keys[1], ExpireSeconds: 600, Result: &result,
+		FallbackFunc: func() (interface{}, error) {
+			return "value", nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+}
+
+func TestCacheWrapperMgetMaxConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	keys := make([]string, 10)
+	expireSeconds := make([]int, len(keys))
+	fallbackFuncSlice := make([]MgetFallbackFunc, len(keys))
+
+	var running, maxRunning int32
+
+	for i := range keys {
+		keys[i] = fmt.Sprintf("gotest:redis_wrapper:max_concurrency%d", i)
+		expireSeconds[i] = 600
+		fallbackFuncSlice[i] = func(fallbackIndex int) (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond * 50)
+
+			return fmt.Sprintf("value%d", fallbackIndex), nil
+		}
+	}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	dataResult := make([]string, len(keys))
+
+	err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+		Keys: keys, ExpireSeconds: expireSeconds, ResultSlice: &dataResult,
+		FallbackFuncSlice: fallbackFuncSlice, MaxConcurrency: 2,
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value9", dataResult[9])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+}
@@ -1,13 +1,18 @@
 module github.com/cclehui/redisutil
 
-go 1.15
+go 1.18
 
 require (
-	github.com/gomodule/redigo v2.0.0+incompatible
-	github.com/kr/pretty v0.1.0 // indirect
+	github.com/gomodule/redigo v1.8.9
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.8.1
 	golang.org/x/sync v0.1.0
-	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/pretty v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
+)
@@ -0,0 +1,120 @@
+package localcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU 进程内缓存, 限制最大条数和每个条目的最长存活时间
+type LRU struct {
+	mu sync.Mutex
+
+	maxEntries int
+	maxTTL     time.Duration
+
+	ll    *list.List
+	items map[string]*list.Element
+
+	now func() time.Time
+}
+
+type entry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+func NewLRU(maxEntries int, maxTTL time.Duration) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxTTL:     maxTTL,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ele, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	item := ele.Value.(*entry)
+
+	if !c.now().Before(item.expireAt) { // 过期
+		c.removeElement(ele)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(ele)
+
+	return item.value, true
+}
+
+// Set ttl 小于等于0 或超过 maxTTL 时使用 maxTTL
+func (c *LRU) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+
+	if ttl <= 0 || c.maxEntries < 1 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expireAt := c.now().Add(ttl)
+
+	if ele, ok := c.items[key]; ok {
+		item := ele.Value.(*entry)
+		item.value = value
+		item.expireAt = expireAt
+		c.ll.MoveToFront(ele)
+
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value, expireAt: expireAt})
+
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *LRU) Del(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if ele, ok := c.items[key]; ok {
+			c.removeElement(ele)
+		}
+	}
+}
+
+// Purge 清空所有条目
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *LRU) removeElement(ele *list.Element) {
+	c.ll.Remove(ele)
+	delete(c.items, ele.Value.(*entry).key)
+}
@@ -0,0 +1,45 @@
+package localcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+
+	cache := NewLRU(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", []byte("a"), 0)
+	cache.Set("b", []byte("b"), time.Second)
+
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), value)
+
+	// 超过最大条数淘汰最久未使用的 b
+	cache.Set("c", []byte("c"), time.Hour)
+	assert.Equal(t, 2, cache.Len())
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	// 过期, ttl 最长为 maxTTL
+	now = now.Add(time.Minute)
+
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+
+	_, ok = cache.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Len())
+
+	cache.Set("d", []byte("d"), 0)
+	cache.Del("d")
+
+	_, ok = cache.Get("d")
+	assert.False(t, ok)
+}
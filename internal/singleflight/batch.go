@@ -0,0 +1,110 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+)
+
+// errBatchPanic 执行批量函数的调用方 panic 或 Goexit, 等待中的调用方返回该错误
+var errBatchPanic = errors.New("batch function panicked or called runtime.Goexit")
+
+// BatchGroup 按单个 key 去重的批量 singleflight
+// 已经在执行中的 key 等待其结果, 其余的 key 一次性交给 fn 获取, 结果分发给所有等待的调用方
+type BatchGroup struct {
+	mu sync.Mutex
+	m  map[string]*batchCall
+}
+
+type batchCall struct {
+	done chan struct{}
+
+	val interface{}
+	ok  bool // fn 的结果中是否有该 key
+	err error
+}
+
+// Do fn 的参数为需要获取的 key 在 keys 中的下标, 返回下标 => 值
+// 返回 keys 下标 => 值, fn 没有返回的 key 不在结果中, 自己或等待的 fn 返回错误时返回该错误
+func (g *BatchGroup) Do(keys []string,
+	fn func(indexes []int) (map[int]interface{}, error)) (map[int]interface{}, error) {
+	ownCalls := make(map[int]*batchCall) // 由当前调用方执行的
+	ownIndexes := make([]int, 0, len(keys))
+	waitCalls := make(map[int]*batchCall, len(keys)) // 所有 key 对应的 call, 包括自己执行的
+
+	g.mu.Lock()
+
+	if g.m == nil {
+		g.m = make(map[string]*batchCall)
+	}
+
+	for i, key := range keys {
+		if c, ok := g.m[key]; ok { // 执行中或者 keys 中重复的 key
+			waitCalls[i] = c
+			continue
+		}
+
+		c := &batchCall{done: make(chan struct{})}
+		g.m[key] = c
+		ownCalls[i] = c
+		ownIndexes = append(ownIndexes, i)
+		waitCalls[i] = c
+	}
+
+	g.mu.Unlock()
+
+	var err error
+
+	if len(ownIndexes) > 0 {
+		err = g.doCall(keys, ownIndexes, ownCalls, fn)
+	}
+
+	result := make(map[int]interface{}, len(keys))
+
+	for i, c := range waitCalls {
+		<-c.done
+
+		if c.err != nil && err == nil {
+			err = c.err
+		}
+
+		if c.ok {
+			result[i] = c.val
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (g *BatchGroup) doCall(keys []string, indexes []int, calls map[int]*batchCall,
+	fn func(indexes []int) (map[int]interface{}, error)) (err error) {
+	var data map[int]interface{}
+
+	normalReturn := false
+
+	defer func() {
+		if !normalReturn {
+			err = errBatchPanic
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		for _, i := range indexes {
+			c := calls[i]
+			c.val, c.ok = data[i]
+			c.err = err
+
+			delete(g.m, keys[i])
+			close(c.done)
+		}
+	}()
+
+	data, err = fn(indexes)
+	normalReturn = true
+
+	return err
+}
@@ -0,0 +1,97 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchDo(t *testing.T) {
+	var g BatchGroup
+
+	var mu sync.Mutex
+
+	called := make([][]string, 0)
+	started := make(chan struct{})
+
+	fn := func(keys []string) func(indexes []int) (map[int]interface{}, error) {
+		return func(indexes []int) (map[int]interface{}, error) {
+			callKeys := make([]string, len(indexes))
+			result := make(map[int]interface{})
+
+			for j, i := range indexes {
+				callKeys[j] = keys[i]
+
+				if keys[i] != "c" { // c 不存在
+					result[i] = "value_" + keys[i]
+				}
+			}
+
+			mu.Lock()
+			called = append(called, callKeys)
+			mu.Unlock()
+
+			if keys[0] == "a" {
+				close(started)
+				time.Sleep(time.Millisecond * 100)
+			}
+
+			return result, nil
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		keys := []string{"a", "b", "c"}
+		result, err := g.Do(keys, fn(keys))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[int]interface{}{0: "value_a", 1: "value_b"}, result)
+	}()
+
+	<-started
+
+	// b, c 正在执行中, 只获取 d, 重复的 d 只获取一次
+	keys := []string{"b", "c", "d", "d"}
+	result, err := g.Do(keys, fn(keys))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, map[int]interface{}{0: "value_b", 2: "value_d", 3: "value_d"}, result)
+
+	wg.Wait()
+
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"d"}}, called)
+}
+
+func TestBatchDoErr(t *testing.T) {
+	var g BatchGroup
+
+	someErr := errors.New("some error")
+	started := make(chan struct{})
+
+	go func() {
+		_, _ = g.Do([]string{"a"}, func(indexes []int) (map[int]interface{}, error) {
+			close(started)
+			time.Sleep(time.Millisecond * 100)
+
+			return nil, someErr
+		})
+	}()
+
+	<-started
+
+	// 等待的 key 出错时同样返回错误
+	result, err := g.Do([]string{"a", "b"}, func(indexes []int) (map[int]interface{}, error) {
+		assert.Equal(t, []int{1}, indexes)
+
+		return map[int]interface{}{1: "value_b"}, nil
+	})
+	assert.Equal(t, someErr, err)
+	assert.Nil(t, result)
+}
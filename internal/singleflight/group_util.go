@@ -17,3 +17,11 @@ func GetGroup(groupKey string) *Group {
 
 	return newGroup
 }
+
+var batchGroupMap sync.Map
+
+func GetBatchGroup(groupKey string) *BatchGroup {
+	value, _ := batchGroupMap.LoadOrStore(groupKey, &BatchGroup{})
+
+	return value.(*BatchGroup)
+}
@@ -0,0 +1,218 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// 一级缓存失效通知, 通过 redis pub/sub 广播给所有进程
+const (
+	invalidationHealthCheckPeriod = 30 * time.Second
+	invalidationMinBackoff        = 100 * time.Millisecond
+	invalidationMaxBackoff        = 10 * time.Second
+)
+
+type invalidationMessage struct {
+	Origin string   `json:"origin"` // 发送方实例, 忽略自己发送的消息
+	Keys   []string `json:"keys"`   // keyPatch 之后的 key
+
+	KeyVersion int64 `json:"key_version,omitempty"` // BumpKeyVersion 升级后的版本
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// invalidate 删除当前进程的一级缓存并通知其他进程
+func (ru *RedisUtil) invalidate(ctx context.Context, keys ...string) {
+	ru.localCacheDel(keys...)
+	ru.publishInvalidation(ctx, keys...)
+}
+
+func (ru *RedisUtil) publishInvalidation(ctx context.Context, keys ...string) {
+	if ru.invalidationChannel == "" || len(keys) < 1 {
+		return
+	}
+
+	patchedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		patchedKeys[i] = ru.keyPatch(key)
+	}
+
+	ru.publishInvalidationMessage(ctx, &invalidationMessage{Origin: ru.instanceID, Keys: patchedKeys})
+}
+
+// 通知其他进程 key 版本已升级
+func (ru *RedisUtil) publishKeyVersion(ctx context.Context, version int64) {
+	if ru.invalidationChannel == "" {
+		return
+	}
+
+	ru.publishInvalidationMessage(ctx, &invalidationMessage{Origin: ru.instanceID, KeyVersion: version})
+}
+
+func (ru *RedisUtil) publishInvalidationMessage(ctx context.Context, message *invalidationMessage) {
+	data, _ := json.Marshal(message)
+
+	err := ru.WrapDo(ctx, func(con redis.Conn) error {
+		_, err := conDo(ctx, con, "PUBLISH", ru.invalidationChannel, data)
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		ru.getLogger().Errorf(ctx, "CacheUtil.publishInvalidation, error:%+v", err)
+	}
+}
+
+// 开启了一级缓存或设置了命名空间时订阅, 分别用于删除一级缓存和同步 key 版本
+func (ru *RedisUtil) startInvalidationSubscriber() {
+	if ru.invalidationChannel == "" || (ru.localCache == nil && ru.keyNamespace == "") {
+		return
+	}
+
+	ru.closeWG.Add(1)
+
+	go func() {
+		defer ru.closeWG.Done()
+
+		ru.runInvalidationSubscriber()
+	}()
+}
+
+// 连接断开后自动重新订阅, 断开期间可能丢失消息, 重新订阅成功后清空一级缓存
+func (ru *RedisUtil) runInvalidationSubscriber() {
+	ctx := context.Background()
+	backoff := invalidationMinBackoff
+	reconnect := false
+
+	for {
+		subscribed, err := ru.subscribeInvalidation(reconnect)
+
+		select {
+		case <-ru.closeCh:
+			return
+		default:
+		}
+
+		if subscribed {
+			backoff = invalidationMinBackoff
+		}
+
+		ru.getLogger().Errorf(ctx, "CacheUtil.runInvalidationSubscriber, resubscribe after %s, error:%+v", backoff, err)
+
+		select {
+		case <-ru.closeCh:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > invalidationMaxBackoff {
+			backoff = invalidationMaxBackoff
+		}
+
+		reconnect = true
+	}
+}
+
+// 阻塞直到连接异常或 Close, subscribed 表示是否订阅成功过
+func (ru *RedisUtil) subscribeInvalidation(reconnect bool) (subscribed bool, err error) {
+	con := ru.pool.Get()
+	defer con.Close()
+
+	psc := redis.PubSubConn{Conn: con}
+
+	if err = psc.Subscribe(ru.invalidationChannel); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		for {
+			switch v := psc.ReceiveWithTimeout(invalidationHealthCheckPeriod * 2).(type) {
+			case error:
+				done <- errors.WithStack(v)
+				return
+			case redis.Subscription:
+				if v.Kind == "subscribe" {
+					subscribed = true
+
+					if reconnect && ru.localCache != nil {
+						ru.localCache.Purge()
+					}
+				}
+
+				if v.Count == 0 { // 取消订阅
+					done <- nil
+					return
+				}
+			case redis.Message:
+				ru.handleInvalidationMessage(v.Data)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(invalidationHealthCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err = psc.Ping(""); err != nil {
+				_ = psc.Unsubscribe()
+				<-done
+
+				return subscribed, errors.WithStack(err)
+			}
+		case <-ru.closeCh:
+			_ = psc.Unsubscribe()
+			err = <-done
+
+			return subscribed, err
+		case err = <-done:
+			return subscribed, err
+		}
+	}
+}
+
+func (ru *RedisUtil) handleInvalidationMessage(data []byte) {
+	message := &invalidationMessage{}
+
+	if err := json.Unmarshal(data, message); err != nil {
+		ru.getLogger().Errorf(context.Background(),
+			"CacheUtil.handleInvalidationMessage, message:%s, error:%+v", string(data), err)
+
+		return
+	}
+
+	if message.Origin == ru.instanceID {
+		return
+	}
+
+	if message.KeyVersion > 0 {
+		ru.advanceKeyVersion(message.KeyVersion)
+	}
+
+	if ru.localCache != nil {
+		ru.localCache.Del(message.Keys...)
+	}
+}
+
+// Close 停止一级缓存失效通知的订阅和 key 版本的后台同步, 不会关闭连接池
+func (ru *RedisUtil) Close() {
+	ru.closeOnce.Do(func() {
+		close(ru.closeCh)
+	})
+
+	ru.closeWG.Wait()
+}
@@ -0,0 +1,132 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+
+	channel := "gotest:redis_util:invalidation"
+	key := "gotest:redis_util:invalidation_key"
+
+	redisUtil1 := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute), OptionInvalidation(channel))
+	defer redisUtil1.Close()
+
+	redisUtil2 := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute), OptionInvalidation(channel))
+	defer redisUtil2.Close()
+
+	time.Sleep(time.Millisecond * 100) // 等待订阅成功
+
+	fallbackValue := "value1"
+	getFunc := func(redisUtil *RedisUtil, flushCache bool) string {
+		result := ""
+
+		err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: key, ExpireSeconds: 600, Result: &result, FlushCache: flushCache,
+			FallbackFunc: func() (interface{}, error) {
+				return fallbackValue, nil
+			},
+		})
+		assert.Equal(t, nil, err)
+
+		return result
+	}
+
+	assert.Equal(t, "value1", getFunc(redisUtil1, false))
+	assert.Equal(t, "value1", getFunc(redisUtil2, false))
+
+	_, ok := redisUtil2.localCacheGet(key)
+	assert.True(t, ok)
+
+	// Set 通知其他进程删除一级缓存
+	err := redisUtil1.Set(ctx, key, "value2", 600)
+	assert.Equal(t, nil, err)
+
+	time.Sleep(time.Millisecond * 100)
+
+	_, ok = redisUtil2.localCacheGet(key)
+	assert.False(t, ok)
+	assert.Equal(t, "value2", getFunc(redisUtil2, false))
+
+	// FlushCache 通知其他进程
+	fallbackValue = "value3"
+	assert.Equal(t, "value3", getFunc(redisUtil1, true))
+
+	time.Sleep(time.Millisecond * 100)
+
+	assert.Equal(t, "value3", getFunc(redisUtil2, false))
+
+	// Del
+	_ = redisUtil2.Del(ctx, key)
+
+	time.Sleep(time.Millisecond * 100)
+
+	_, ok = redisUtil1.localCacheGet(key)
+	assert.False(t, ok)
+}
+
+func TestInvalidationMgetFill(t *testing.T) {
+	ctx := context.Background()
+
+	channel := "gotest:redis_util:invalidation_mget"
+	keys := []string{"gotest:redis_util:invalidation_mget1", "gotest:redis_util:invalidation_mget2"}
+
+	redisUtil1 := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute), OptionInvalidation(channel))
+	defer redisUtil1.Close()
+
+	redisUtil2 := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute), OptionInvalidation(channel))
+	defer redisUtil2.Close()
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil1.Del(ctx, key)
+		}
+	}()
+
+	time.Sleep(time.Millisecond * 100) // 等待订阅成功
+
+	getFunc := func(redisUtil *RedisUtil, flushCache bool) {
+		dataResult := make([]string, len(keys))
+
+		err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+			Keys: keys, ExpireSeconds: []int{600, 600}, ResultSlice: &dataResult, FlushCache: flushCache,
+			BatchFallbackFunc: func(fallbackIndexes []int) (map[int]interface{}, error) {
+				result := make(map[int]interface{})
+				for _, i := range fallbackIndexes {
+					result[i] = "value"
+				}
+
+				return result, nil
+			},
+		})
+		assert.Equal(t, nil, err)
+	}
+
+	// redisUtil2 先写入一级缓存
+	getFunc(redisUtil2, false)
+
+	// 不发送通知的删除, redisUtil1 读取时未命中并回写
+	_ = NewRedisUtil(getTestPool()).Del(ctx, keys[1])
+
+	// 未命中的回写不通知其他进程
+	getFunc(redisUtil1, false)
+
+	time.Sleep(time.Millisecond * 100)
+
+	_, ok := redisUtil2.localCacheGet(keys[1])
+	assert.True(t, ok)
+
+	// FlushCache 通知其他进程
+	getFunc(redisUtil1, true)
+
+	time.Sleep(time.Millisecond * 100)
+
+	_, ok = redisUtil2.localCacheGet(keys[1])
+	assert.False(t, ok)
+}
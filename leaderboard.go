@@ -0,0 +1,382 @@
+package redisutil
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// LeaderboardPolicy 提交分数时和已有分数的合并方式
+type LeaderboardPolicy string
+
+const (
+	LeaderboardPolicyBest   LeaderboardPolicy = "best"   // 保留最高分
+	LeaderboardPolicySum    LeaderboardPolicy = "sum"    // 累加
+	LeaderboardPolicyLatest LeaderboardPolicy = "latest" // 覆盖为最新的分数
+)
+
+// LeaderboardPeriod 周期榜单, 每个周期使用单独的 key
+type LeaderboardPeriod int
+
+const (
+	LeaderboardPeriodNone   LeaderboardPeriod = iota // 不分周期
+	LeaderboardPeriodDaily                           // 按天, key 后缀为当天日期 20060102
+	LeaderboardPeriodWeekly                          // 按周(周一开始), key 后缀为周一的日期
+)
+
+// 非周期榜单同分排序的时间起点和范围(秒)
+var leaderboardTieBreakEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const leaderboardTieBreakRange = 1 << 31
+
+// LeaderboardParams Leaderboard 的参数
+type LeaderboardParams struct {
+	Name   string            // 榜单 key 的前缀, 元数据 hash 的 key 为 Name + ":meta"
+	Policy LeaderboardPolicy // 默认 LeaderboardPolicyBest
+	Period LeaderboardPeriod
+
+	Location      *time.Location // 周期的时区, 默认 time.Local
+	RetainSeconds int            // 周期榜单结束后保留的时间, 默认保留一个周期
+
+	// 同分时先达到该分数的排在前面, 分数的小数部分用于存储时间, 所以分数必须是整数
+	// 精度限制: 分数的绝对值 * 周期的秒数要小于 2^53, 非周期榜单按 2^31 秒计算, 即分数的绝对值小于 2^22
+	TieBreak bool
+}
+
+// LeaderboardEntry 榜单中的成员, Rank 从 1 开始
+type LeaderboardEntry struct {
+	Rank   int64
+	Member string
+	Score  float64
+}
+
+// Leaderboard 基于 sorted set 的排行榜, 分数从大到小排名
+type Leaderboard struct {
+	redisUtil *RedisUtil
+	params    LeaderboardParams
+
+	at time.Time // 不为零值时固定使用该时间所在的周期, 见 At
+}
+
+// 合并分数, 写入并设置过期时间, 返回合并后的分数(不含同分排序的小数部分)
+// KEYS[1] 榜单 key, ARGV: member, score, policy, 同分排序的小数部分(为空时不开启), expireAt(为 0 时不设置)
+var leaderboardSubmitScript = redis.NewScript(1, `
+local score = tonumber(ARGV[2])
+local fraction = tonumber(ARGV[4])
+
+local current = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if current then
+	current = tonumber(current)
+	if fraction then
+		current = math.floor(current)
+	end
+end
+
+local newScore = score
+if ARGV[3] == "sum" then
+	if current then
+		newScore = current + score
+	end
+elseif ARGV[3] == "best" then
+	if current and current >= score then
+		newScore = nil
+	end
+end
+
+if newScore then
+	local value = newScore
+	if fraction then
+		value = newScore + fraction
+	end
+	redis.call("ZADD", KEYS[1], string.format("%.17g", value), ARGV[1])
+else
+	newScore = current
+end
+
+local expireAt = tonumber(ARGV[5])
+if expireAt > 0 then
+	redis.call("EXPIREAT", KEYS[1], expireAt)
+end
+
+return string.format("%.17g", newScore)
+`)
+
+func NewLeaderboard(redisUtil *RedisUtil, params *LeaderboardParams) (*Leaderboard, error) {
+	if params.Name == "" {
+		return nil, errors.New("leaderboard name is empty")
+	}
+
+	lb := &Leaderboard{redisUtil: redisUtil, params: *params}
+
+	switch lb.params.Policy {
+	case "":
+		lb.params.Policy = LeaderboardPolicyBest
+	case LeaderboardPolicyBest, LeaderboardPolicySum, LeaderboardPolicyLatest:
+	default:
+		return nil, errors.Errorf("unknown leaderboard policy:%s", lb.params.Policy)
+	}
+
+	if lb.params.Location == nil {
+		lb.params.Location = time.Local
+	}
+
+	return lb, nil
+}
+
+// At 返回使用 t 所在周期的榜单, 用于查询或补录历史周期
+func (lb *Leaderboard) At(t time.Time) *Leaderboard {
+	newLb := *lb
+	newLb.at = t
+
+	return &newLb
+}
+
+func (lb *Leaderboard) now() time.Time {
+	if !lb.at.IsZero() {
+		return lb.at
+	}
+
+	return time.Now()
+}
+
+// 周期的开始和结束时间, 非周期榜单返回零值
+func (lb *Leaderboard) periodRange(t time.Time) (start, end time.Time) {
+	t = t.In(lb.params.Location)
+	dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, lb.params.Location)
+
+	switch lb.params.Period {
+	case LeaderboardPeriodDaily:
+		return dayStart, dayStart.AddDate(0, 0, 1)
+	case LeaderboardPeriodWeekly:
+		start = dayStart.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	default:
+		return time.Time{}, time.Time{}
+	}
+}
+
+// Key 当前周期榜单的 key (未经过 keyPatch)
+func (lb *Leaderboard) Key() string {
+	start, _ := lb.periodRange(lb.now())
+	if start.IsZero() {
+		return lb.params.Name
+	}
+
+	return lb.params.Name + ":" + start.Format("20060102")
+}
+
+func (lb *Leaderboard) metaKey() string {
+	return lb.params.Name + ":meta"
+}
+
+// 过期时间, 非周期榜单返回 0
+func (lb *Leaderboard) expireAt() int64 {
+	start, end := lb.periodRange(lb.now())
+	if start.IsZero() {
+		return 0
+	}
+
+	retain := end.Sub(start)
+	if lb.params.RetainSeconds > 0 {
+		retain = time.Duration(lb.params.RetainSeconds) * time.Second
+	}
+
+	return end.Add(retain).Unix()
+}
+
+// 同分排序的小数部分, 越早越大, 范围 (0, 1)
+func (lb *Leaderboard) tieBreakFraction() float64 {
+	now := lb.now()
+
+	start, end := lb.periodRange(now)
+	rangeSeconds := int64(leaderboardTieBreakRange)
+
+	if start.IsZero() {
+		start = leaderboardTieBreakEpoch
+	} else {
+		rangeSeconds = int64(end.Sub(start) / time.Second)
+	}
+
+	elapsed := int64(now.Sub(start) / time.Second)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	if elapsed >= rangeSeconds {
+		elapsed = rangeSeconds - 1
+	}
+
+	return float64(rangeSeconds-elapsed) / float64(rangeSeconds+1)
+}
+
+// 去掉同分排序的小数部分
+func (lb *Leaderboard) score(value float64) float64 {
+	if lb.params.TieBreak {
+		return math.Floor(value)
+	}
+
+	return value
+}
+
+// Submit 按 Policy 提交分数, 返回成员当前的分数
+func (lb *Leaderboard) Submit(ctx context.Context, member string, score float64) (newScore float64, err error) {
+	fraction := ""
+
+	if lb.params.TieBreak {
+		if score != math.Trunc(score) {
+			return 0, errors.Errorf("leaderboard score must be integer when TieBreak is enabled, score:%v", score)
+		}
+
+		fraction = strconv.FormatFloat(lb.tieBreakFraction(), 'g', -1, 64)
+	}
+
+	ru := lb.redisUtil
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		reply, err2 := redis.String(leaderboardSubmitScript.DoContext(ctx, con,
+			ru.keyPatch(lb.Key()), member, score, string(lb.params.Policy), fraction, lb.expireAt()))
+		if err2 != nil {
+			return errors.WithStack(ctxError(ctx, err2))
+		}
+
+		newScore, err2 = strconv.ParseFloat(reply, 64)
+
+		return errors.WithStack(err2)
+	})
+
+	return newScore, err
+}
+
+// Rank 成员的排名和分数, 成员不在榜单中时 hit 为 false
+func (lb *Leaderboard) Rank(ctx context.Context, member string) (entry *LeaderboardEntry, hit bool, err error) {
+	ru := lb.redisUtil
+	key := ru.keyPatch(lb.Key())
+
+	var rank int64
+
+	var score float64
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		if err2 := conSend(ctx, con, "ZREVRANK", key, member); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conSend(ctx, con, "ZSCORE", key, member); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conFlush(ctx, con); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		var err2 error
+
+		rank, err2 = redis.Int64(conReceive(ctx, con))
+		if err2 != nil && err2 != redis.ErrNil {
+			return errors.WithStack(err2)
+		}
+
+		hit = err2 == nil
+
+		score, err2 = redis.Float64(conReceive(ctx, con))
+		if err2 != nil && err2 != redis.ErrNil {
+			return errors.WithStack(err2)
+		}
+
+		hit = hit && err2 == nil
+
+		return nil
+	})
+
+	if err != nil || !hit {
+		return nil, false, err
+	}
+
+	return &LeaderboardEntry{Rank: rank + 1, Member: member, Score: lb.score(score)}, true, nil
+}
+
+// Top 前 n 名
+func (lb *Leaderboard) Top(ctx context.Context, n int) (entries []*LeaderboardEntry, err error) {
+	if n < 1 {
+		return []*LeaderboardEntry{}, nil
+	}
+
+	return lb.rangeEntries(ctx, 0, n-1)
+}
+
+// AroundMe 成员及其前后各 n 名, 成员不在榜单中时 hit 为 false
+func (lb *Leaderboard) AroundMe(ctx context.Context,
+	member string, n int) (entries []*LeaderboardEntry, hit bool, err error) {
+	entry, hit, err := lb.Rank(ctx, member)
+	if err != nil || !hit {
+		return nil, hit, err
+	}
+
+	start := int(entry.Rank-1) - n
+	if start < 0 {
+		start = 0
+	}
+
+	entries, err = lb.rangeEntries(ctx, start, int(entry.Rank-1)+n)
+
+	return entries, err == nil, err
+}
+
+func (lb *Leaderboard) rangeEntries(ctx context.Context, start, end int) (entries []*LeaderboardEntry, err error) {
+	infos, err := lb.redisUtil.ZRevRangeWithScores(ctx, lb.Key(), start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = make([]*LeaderboardEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = &LeaderboardEntry{Rank: int64(start + i + 1), Member: info.Name, Score: lb.score(info.Score)}
+	}
+
+	return entries, nil
+}
+
+// Count 榜单中的成员数量
+func (lb *Leaderboard) Count(ctx context.Context) (int64, error) {
+	return lb.redisUtil.ZCard(ctx, lb.Key())
+}
+
+// Remove 从榜单中删除成员, 不删除元数据
+func (lb *Leaderboard) Remove(ctx context.Context, members ...string) error {
+	if len(members) < 1 {
+		return nil
+	}
+
+	return lb.redisUtil.ZRem(ctx, lb.Key(), members)
+}
+
+// SetMeta 设置成员的元数据(昵称, 头像等), 所有周期共用, 通过 codec 编码
+func (lb *Leaderboard) SetMeta(ctx context.Context, member string, value interface{}) error {
+	return lb.redisUtil.HSet(ctx, lb.metaKey(), member, value)
+}
+
+// GetMeta value 必须是指针, 没有元数据时 hit 为 false
+func (lb *Leaderboard) GetMeta(ctx context.Context, member string, value interface{}) (hit bool, err error) {
+	return lb.redisUtil.HGet(ctx, lb.metaKey(), member, value)
+}
+
+// GetMetas valuesInter 必须是和 members 等长的 slice 的指针, 例如配合 Top 的结果一次获取
+func (lb *Leaderboard) GetMetas(ctx context.Context,
+	members []string, valuesInter interface{}) (hits []bool, err error) {
+	return lb.redisUtil.HMGet(ctx, lb.metaKey(), members, valuesInter)
+}
+
+// DelMeta 删除成员的元数据
+func (lb *Leaderboard) DelMeta(ctx context.Context, members ...string) error {
+	if len(members) < 1 {
+		return nil
+	}
+
+	_, err := lb.redisUtil.HDel(ctx, lb.metaKey(), members...)
+
+	return err
+}
@@ -0,0 +1,180 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderboardPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	for _, testCase := range []struct {
+		policy LeaderboardPolicy
+		scores []float64
+		expect float64
+	}{
+		{LeaderboardPolicyBest, []float64{10, 30, 20}, 30},
+		{LeaderboardPolicySum, []float64{10, 30, 20}, 60},
+		{LeaderboardPolicyLatest, []float64{10, 30, 20}, 20},
+	} {
+		lb, err := NewLeaderboard(redisUtil, &LeaderboardParams{
+			Name:   "gotest:leaderboard:policy:" + string(testCase.policy),
+			Policy: testCase.policy,
+		})
+		assert.Equal(t, nil, err)
+
+		var score float64
+		for _, s := range testCase.scores {
+			score, err = lb.Submit(ctx, "user1", s)
+			assert.Equal(t, nil, err)
+		}
+
+		assert.Equal(t, testCase.expect, score)
+
+		entry, hit, err := lb.Rank(ctx, "user1")
+		assert.Equal(t, nil, err)
+		assert.True(t, hit)
+		assert.Equal(t, &LeaderboardEntry{Rank: 1, Member: "user1", Score: testCase.expect}, entry)
+
+		_ = redisUtil.Del(ctx, lb.Key())
+	}
+
+	_, err := NewLeaderboard(redisUtil, &LeaderboardParams{Name: "gotest:leaderboard", Policy: "max"})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestLeaderboardRank(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	lb, err := NewLeaderboard(redisUtil, &LeaderboardParams{
+		Name:     "gotest:leaderboard:rank",
+		Policy:   LeaderboardPolicySum,
+		TieBreak: true,
+	})
+	assert.Equal(t, nil, err)
+
+	defer func() {
+		_ = redisUtil.Del(ctx, lb.Key())
+		_ = redisUtil.Del(ctx, lb.metaKey())
+	}()
+
+	now := time.Now()
+
+	// user2 和 user3 同分, user3 先达到, 排在前面
+	_, _ = lb.At(now).Submit(ctx, "user1", 100)
+	_, _ = lb.At(now.Add(-time.Minute)).Submit(ctx, "user3", 50)
+	_, _ = lb.At(now).Submit(ctx, "user2", 20)
+	score, err := lb.At(now.Add(time.Second)).Submit(ctx, "user2", 30)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, float64(50), score)
+	_, _ = lb.At(now).Submit(ctx, "user4", 10)
+	_, _ = lb.At(now).Submit(ctx, "user5", 5)
+
+	_, err = lb.Submit(ctx, "user1", 1.5)
+	assert.NotEqual(t, nil, err)
+
+	top, err := lb.Top(ctx, 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*LeaderboardEntry{
+		{Rank: 1, Member: "user1", Score: 100},
+		{Rank: 2, Member: "user3", Score: 50},
+		{Rank: 3, Member: "user2", Score: 50},
+	}, top)
+
+	entries, hit, err := lb.AroundMe(ctx, "user2", 1)
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, []*LeaderboardEntry{
+		{Rank: 2, Member: "user3", Score: 50},
+		{Rank: 3, Member: "user2", Score: 50},
+		{Rank: 4, Member: "user4", Score: 10},
+	}, entries)
+
+	entries, _, _ = lb.AroundMe(ctx, "user1", 1)
+	assert.Equal(t, 2, len(entries))
+
+	_, hit, err = lb.AroundMe(ctx, "not_exist", 1)
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+
+	count, _ := lb.Count(ctx)
+	assert.Equal(t, int64(5), count)
+
+	err = lb.Remove(ctx, "user5")
+	assert.Equal(t, nil, err)
+
+	_, hit, _ = lb.Rank(ctx, "user5")
+	assert.False(t, hit)
+
+	type userMeta struct {
+		Nickname string
+	}
+
+	err = lb.SetMeta(ctx, "user1", &userMeta{Nickname: "one"})
+	assert.Equal(t, nil, err)
+
+	meta := &userMeta{}
+	hit, err = lb.GetMeta(ctx, "user1", meta)
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, "one", meta.Nickname)
+
+	metas := make([]*userMeta, 2)
+	hits, err := lb.GetMetas(ctx, []string{"user1", "user2"}, &metas)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false}, hits)
+	assert.Equal(t, "one", metas[0].Nickname)
+}
+
+func TestLeaderboardPeriod(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	lb, err := NewLeaderboard(redisUtil, &LeaderboardParams{
+		Name:     "gotest:leaderboard:weekly",
+		Period:   LeaderboardPeriodWeekly,
+		Location: time.UTC,
+	})
+	assert.Equal(t, nil, err)
+
+	// 2026-10-14 是周三, 所在周从 10-12 周一开始
+	wednesday := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "gotest:leaderboard:weekly:20261012", lb.At(wednesday).Key())
+	assert.Equal(t, "gotest:leaderboard:weekly:20261012", lb.At(wednesday.AddDate(0, 0, 4)).Key())
+	assert.Equal(t, "gotest:leaderboard:weekly:20261019", lb.At(wednesday.AddDate(0, 0, 5)).Key())
+
+	daily, _ := NewLeaderboard(redisUtil, &LeaderboardParams{
+		Name:     "gotest:leaderboard:daily",
+		Period:   LeaderboardPeriodDaily,
+		Location: time.UTC,
+	})
+	assert.Equal(t, "gotest:leaderboard:daily:20261014", daily.At(wednesday).Key())
+
+	defer func() {
+		_ = redisUtil.Del(ctx, lb.Key())
+		_ = redisUtil.Del(ctx, lb.At(time.Now().AddDate(0, 0, -7)).Key())
+	}()
+
+	// 上周的榜单和本周的互不影响
+	_, _ = lb.Submit(ctx, "user1", 10)
+	_, _ = lb.At(time.Now().AddDate(0, 0, -7)).Submit(ctx, "user1", 20)
+
+	entry, _, _ := lb.Rank(ctx, "user1")
+	assert.Equal(t, float64(10), entry.Score)
+
+	// 默认保留到周期结束后一个周期
+	ttl, _ := redisUtil.TTL(ctx, lb.Key())
+	assert.True(t, ttl > 7*86400 && ttl <= 14*86400)
+
+	ttl, _ = redisUtil.TTL(ctx, lb.At(time.Now().AddDate(0, 0, -7)).Key())
+	assert.True(t, ttl > 0 && ttl <= 7*86400)
+}
@@ -0,0 +1,190 @@
+package redisutil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLoaderWait     = 2 * time.Millisecond
+	DefaultLoaderMaxBatch = 100
+	DefaultLoaderTimeout  = 3 * time.Second
+)
+
+// LoaderParams Loader 的参数
+type LoaderParams[T any] struct {
+	Wait     time.Duration // 收集 Load 调用的时间窗口, 默认 DefaultLoaderWait
+	MaxBatch int           // 每批最多的 key 数, 达到后立即执行, 默认 DefaultLoaderMaxBatch
+
+	// 每个批次读取缓存和调用 fallback 的超时时间, 默认 DefaultLoaderTimeout
+	// 批次不受调用方 ctx 的取消影响, 超时后等待中的调用方返回 ErrTimeout
+	Timeout time.Duration
+
+	// 为空时只读取缓存, 否则未命中的 key 合并调用一次, 写入缓存的过期时间为 ExpireSeconds, 同 CacheWrapperMget
+	BatchFallbackFunc func(keys []string) (map[string]T, error)
+	ExpireSeconds     int
+	SingleFlight      bool
+
+	Codec Codec // 为空时使用默认 codec
+}
+
+// Loader 把时间窗口内多个 goroutine 的 Load 合并为一次 MGET 和一次批量 fallback (dataloader)
+type Loader[T any] struct {
+	redisUtil *RedisUtil
+	params    LoaderParams[T]
+
+	mu    sync.Mutex
+	batch *loaderBatch[T] // 正在收集的批次
+}
+
+type loaderBatch[T any] struct {
+	ctx   context.Context // 第一个调用方的 ctx, 不继承超时和取消, 执行时加上 Timeout
+	keys  []string
+	index map[string]int // key => keys 下标, 相同的 key 只获取一次
+	timer *time.Timer
+
+	dispatched bool
+	done       chan struct{}
+
+	values []T
+	found  []bool
+	err    error
+}
+
+func NewLoader[T any](redisUtil *RedisUtil, params *LoaderParams[T]) *Loader[T] {
+	loader := &Loader[T]{redisUtil: redisUtil, params: *params}
+
+	if loader.params.Wait <= 0 {
+		loader.params.Wait = DefaultLoaderWait
+	}
+
+	if loader.params.MaxBatch <= 0 {
+		loader.params.MaxBatch = DefaultLoaderMaxBatch
+	}
+
+	if loader.params.Timeout <= 0 {
+		loader.params.Timeout = DefaultLoaderTimeout
+	}
+
+	return loader
+}
+
+// Load hit 为 false 表示缓存中没有且 fallback 没有返回该 key, ctx 只影响当前调用方的等待
+func (l *Loader[T]) Load(ctx context.Context, key string) (value T, hit bool, err error) {
+	l.mu.Lock()
+
+	batch := l.batch
+	if batch == nil {
+		batch = &loaderBatch[T]{
+			ctx:   detachContext(ctx),
+			index: make(map[string]int),
+			done:  make(chan struct{}),
+		}
+		batch.timer = time.AfterFunc(l.params.Wait, func() {
+			l.dispatch(batch)
+		})
+
+		l.batch = batch
+	}
+
+	i, ok := batch.index[key]
+	if !ok {
+		i = len(batch.keys)
+		batch.index[key] = i
+		batch.keys = append(batch.keys, key)
+	}
+
+	full := len(batch.keys) >= l.params.MaxBatch
+	if full { // 之后的调用进入新的批次
+		l.batch = nil
+	}
+
+	l.mu.Unlock()
+
+	if full {
+		batch.timer.Stop()
+		go l.dispatch(batch)
+	}
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return value, false, ctxError(ctx, ctx.Err())
+	}
+
+	if batch.err != nil {
+		return value, false, batch.err
+	}
+
+	return batch.values[i], batch.found[i], nil
+}
+
+// 时间窗口结束或达到 MaxBatch 时执行, 每个批次只执行一次
+func (l *Loader[T]) dispatch(batch *loaderBatch[T]) {
+	l.mu.Lock()
+
+	if batch.dispatched {
+		l.mu.Unlock()
+		return
+	}
+
+	batch.dispatched = true
+
+	if l.batch == batch {
+		l.batch = nil
+	}
+
+	l.mu.Unlock()
+
+	defer close(batch.done)
+
+	ctx, cancel := context.WithTimeout(batch.ctx, l.params.Timeout)
+	defer cancel()
+
+	batch.values = make([]T, len(batch.keys))
+
+	if l.params.BatchFallbackFunc == nil {
+		batch.found, batch.err = l.redisUtil.mget(ctx, batch.keys, &batch.values,
+			l.redisUtil.getValueCodec(l.params.Codec))
+
+		return
+	}
+
+	expireSeconds := make([]int, len(batch.keys))
+	for i := range expireSeconds {
+		expireSeconds[i] = l.params.ExpireSeconds
+	}
+
+	mgetParams := &WrapperParamsMget{
+		Keys:          batch.keys,
+		ExpireSeconds: expireSeconds,
+		ResultSlice:   &batch.values,
+		BatchFallbackFunc: func(fallbackIndexes []int) (map[int]interface{}, error) {
+			fallbackKeys := make([]string, len(fallbackIndexes))
+			for j, fallbackIndex := range fallbackIndexes {
+				fallbackKeys[j] = batch.keys[fallbackIndex]
+			}
+
+			data, err := l.params.BatchFallbackFunc(fallbackKeys)
+			if err != nil {
+				return nil, err
+			}
+
+			result := make(map[int]interface{}, len(data))
+
+			for _, fallbackIndex := range fallbackIndexes {
+				if value, ok := data[batch.keys[fallbackIndex]]; ok {
+					result[fallbackIndex] = value
+				}
+			}
+
+			return result, nil
+		},
+		SingleFlight: l.params.SingleFlight,
+		Codec:        l.params.Codec,
+	}
+
+	batch.err = l.redisUtil.CacheWrapperMget(ctx, mgetParams)
+	batch.found = mgetParams.Found
+}
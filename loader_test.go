@@ -0,0 +1,168 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("gotest:redis_util:loader%d", i)
+	}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	var mu sync.Mutex
+
+	fallbackKeys := make([][]string, 0)
+
+	loader := NewLoader(redisUtil, &LoaderParams[string]{
+		Wait:          time.Millisecond * 50,
+		ExpireSeconds: 600,
+		BatchFallbackFunc: func(keys []string) (map[string]string, error) {
+			mu.Lock()
+			fallbackKeys = append(fallbackKeys, keys)
+			mu.Unlock()
+
+			result := make(map[string]string)
+
+			for _, key := range keys {
+				if key != "gotest:redis_util:loader9" { // loader9 不存在
+					result[key] = "value_" + key
+				}
+			}
+
+			return result, nil
+		},
+	})
+
+	loadAll := func() {
+		goGroup, _ := errgroup.WithContext(ctx)
+
+		for i, key := range keys {
+			i, key := i, key
+
+			goGroup.Go(func() error {
+				value, hit, err := loader.Load(ctx, key)
+				assert.Equal(t, nil, err)
+				assert.Equal(t, i != 9, hit)
+
+				if hit {
+					assert.Equal(t, "value_"+key, value)
+				}
+
+				return nil
+			})
+		}
+
+		_ = goGroup.Wait()
+	}
+
+	// 合并为一次 fallback, 第二次只有不存在的 key 调用 fallback
+	loadAll()
+	assert.Equal(t, 1, len(fallbackKeys))
+	assert.Equal(t, 10, len(fallbackKeys[0]))
+
+	loadAll()
+	assert.Equal(t, [][]string{{"gotest:redis_util:loader9"}}, fallbackKeys[1:])
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	keys := make([]string, 6)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("gotest:redis_util:loader_max_batch%d", i)
+
+		if i%2 == 0 {
+			_ = redisUtil.Set(ctx, keys[i], "value", 600)
+		}
+	}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	// 只读取缓存, 达到 MaxBatch 立即执行, 不等待时间窗口
+	loader := NewLoader(redisUtil, &LoaderParams[string]{Wait: time.Second * 10, MaxBatch: 3})
+
+	var hits int32
+
+	start := time.Now()
+	goGroup, _ := errgroup.WithContext(ctx)
+
+	for _, key := range keys {
+		key := key
+
+		goGroup.Go(func() error {
+			value, hit, err := loader.Load(ctx, key)
+			assert.Equal(t, nil, err)
+
+			if hit {
+				atomic.AddInt32(&hits, 1)
+				assert.Equal(t, "value", value)
+			}
+
+			return nil
+		})
+	}
+
+	_ = goGroup.Wait()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+// 批次超时后等待中的调用方返回 ErrTimeout, 不会一直阻塞
+func TestLoaderTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:loader_timeout"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	loader := NewLoader(redisUtil, &LoaderParams[string]{
+		Timeout:       100 * time.Millisecond,
+		ExpireSeconds: 600,
+		BatchFallbackFunc: func(keys []string) (map[string]string, error) {
+			<-unblock
+
+			return map[string]string{}, nil
+		},
+	})
+
+	start := time.Now()
+
+	_, hit, err := loader.Load(ctx, key)
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.False(t, hit)
+	assert.True(t, time.Since(start) < time.Second)
+}
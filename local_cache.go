@@ -0,0 +1,272 @@
+package redisutil
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CacheStats CacheWrapper, CacheWrapperMget 的一级(进程内)和二级(redis)缓存命中统计
+type CacheStats struct {
+	L1Hits   int64
+	L1Misses int64
+	L2Hits   int64
+	L2Misses int64
+}
+
+type cacheStats struct {
+	l1Hits   int64
+	l1Misses int64
+	l2Hits   int64
+	l2Misses int64
+}
+
+func (ru *RedisUtil) CacheStats() CacheStats {
+	return CacheStats{
+		L1Hits:   atomic.LoadInt64(&ru.stats.l1Hits),
+		L1Misses: atomic.LoadInt64(&ru.stats.l1Misses),
+		L2Hits:   atomic.LoadInt64(&ru.stats.l2Hits),
+		L2Misses: atomic.LoadInt64(&ru.stats.l2Misses),
+	}
+}
+
+func (ru *RedisUtil) statL1(hit bool) {
+	if hit {
+		atomic.AddInt64(&ru.stats.l1Hits, 1)
+	} else {
+		atomic.AddInt64(&ru.stats.l1Misses, 1)
+	}
+}
+
+func (ru *RedisUtil) statL2(hit bool) {
+	if hit {
+		atomic.AddInt64(&ru.stats.l2Hits, 1)
+	} else {
+		atomic.AddInt64(&ru.stats.l2Misses, 1)
+	}
+}
+
+// 一级缓存的 key 带上 keyPatch 的前缀, 升级 key 版本时一级缓存同样失效
+func (ru *RedisUtil) localCacheGet(key string) ([]byte, bool) {
+	if ru.localCache == nil {
+		return nil, false
+	}
+
+	return ru.localCache.Get(ru.keyPatch(key))
+}
+
+func (ru *RedisUtil) localCacheSet(key string, data []byte, ttl int) {
+	if ru.localCache == nil {
+		return
+	}
+
+	var localTTL time.Duration // 不超过 redis 的过期时间, 不过期时使用一级缓存的最长时间
+	if ttl != TTLNoExpire {
+		localTTL = time.Duration(ttl) * time.Second
+	}
+
+	ru.localCache.Set(ru.keyPatch(key), data, localTTL)
+}
+
+// 从 redis 读取后写入一级缓存, 过期时间为 redis 中剩余的过期时间(PTTL 毫秒), 一级缓存不会比 redis 存活更久
+func (ru *RedisUtil) localCacheSetPTTL(key string, data []byte, pttl int64) {
+	if ru.localCache == nil {
+		return
+	}
+
+	if pttl == -1 { // redis 中不过期, 使用一级缓存的最长时间
+		ru.localCache.Set(ru.keyPatch(key), data, 0)
+		return
+	}
+
+	if pttl <= 0 { // 已过期
+		return
+	}
+
+	ru.localCache.Set(ru.keyPatch(key), data, time.Duration(pttl)*time.Millisecond)
+}
+
+func (ru *RedisUtil) localCacheDel(keys ...string) {
+	if ru.localCache == nil {
+		return
+	}
+
+	for _, key := range keys {
+		ru.localCache.Del(ru.keyPatch(key))
+	}
+}
+
+// 先读一级缓存再读 redis, redis 命中后写入一级缓存
+func (ru *RedisUtil) tieredGet(ctx context.Context,
+	key string, value interface{}, vc *valueCodec) (hit bool, meta *valueMeta, err error) {
+	defer func() {
+		if err != nil {
+			ru.getLogger().Errorf(ctx, "CacheUtil.tieredGet, error:%+v", errors.WithStack(err))
+		}
+	}()
+
+	if reflect.ValueOf(value).Kind() != reflect.Ptr {
+		return false, nil, errors.New("value must be ptr")
+	}
+
+	if ru.localCache != nil {
+		if data, ok := ru.localCacheGet(key); ok {
+			if hit, meta, err = vc.decodeWithMeta(data, value); err == nil && hit {
+				ru.statL1(true)
+				return true, meta, nil
+			}
+		}
+
+		ru.statL1(false)
+	}
+
+	var (
+		data []byte
+		pttl int64
+	)
+
+	if ru.localCache != nil {
+		data, pttl, err = ru.getBytesWithPTTL(ctx, key)
+	} else {
+		data, err = ru.getBytes(ctx, key)
+	}
+
+	if err != nil {
+		return false, nil, err
+	}
+
+	ru.statL2(data != nil)
+
+	if data == nil {
+		return false, nil, nil
+	}
+
+	if hit, meta, err = vc.decodeWithMeta(data, value); err != nil || !hit {
+		return false, nil, err
+	}
+
+	if !meta.notFound { // 不存在的标记的过期时间和 ttl 不同, 不写入一级缓存
+		ru.localCacheSetPTTL(key, data, pttl)
+	}
+
+	return true, meta, nil
+}
+
+// 先读一级缓存, 未命中的 key 再从 redis mget
+func (ru *RedisUtil) tieredMGet(ctx context.Context, keys []string,
+	valuesInter interface{}, vc *valueCodec) (hits []bool, metas []*valueMeta, err error) {
+	defer func() {
+		if err != nil {
+			ru.getLogger().Errorf(ctx, "CacheUtil.tieredMGet, error:%+v", err)
+		}
+	}()
+
+	valuesInterRFElem, err := sliceResultValue(keys, valuesInter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hits = make([]bool, len(keys))
+	metas = make([]*valueMeta, len(keys))
+	redisIndexes := make([]int, 0, len(keys)) // 需要从 redis 获取的
+
+	for i, key := range keys {
+		if ru.localCache == nil {
+			redisIndexes = append(redisIndexes, i)
+			continue
+		}
+
+		if data, ok := ru.localCacheGet(key); ok {
+			hit, meta, err2 := vc.decodeWithMeta(data, valuesInterRFElem.Index(i).Addr().Interface())
+			if err2 == nil && hit {
+				ru.statL1(true)
+
+				hits[i] = true
+				metas[i] = meta
+
+				continue
+			}
+		}
+
+		ru.statL1(false)
+
+		redisIndexes = append(redisIndexes, i)
+	}
+
+	if len(redisIndexes) < 1 {
+		return hits, metas, nil
+	}
+
+	redisKeys := make([]string, len(redisIndexes))
+	for j, index := range redisIndexes {
+		redisKeys[j] = keys[index]
+	}
+
+	var (
+		redisResult [][]byte
+		pttls       []int64
+	)
+
+	if ru.localCache != nil {
+		redisResult, pttls, err = ru.mgetBytesWithPTTL(ctx, redisKeys)
+	} else {
+		redisResult, err = ru.mgetBytes(ctx, redisKeys)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for j, data := range redisResult {
+		index := redisIndexes[j]
+
+		ru.statL2(data != nil)
+
+		if data == nil {
+			continue
+		}
+
+		hits[index], metas[index], err = vc.decodeWithMeta(data, valuesInterRFElem.Index(index).Addr().Interface())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ru.localCache != nil && hits[index] && !metas[index].notFound {
+			ru.localCacheSetPTTL(keys[index], data, pttls[j])
+		}
+	}
+
+	return hits, metas, nil
+}
+
+// 写入 redis 和一级缓存
+func (ru *RedisUtil) tieredSet(ctx context.Context,
+	key string, value interface{}, ttl int, vc *valueCodec, meta *valueMeta) error {
+	bytesData, err := vc.encodeWithMeta(value, meta)
+	if err != nil {
+		return err
+	}
+
+	ru.localCacheSet(key, bytesData, ttl)
+
+	return ru.setBytes(ctx, key, bytesData, ttl)
+}
+
+// 批量写入 redis 和一级缓存
+func (ru *RedisUtil) tieredBatchSet(ctx context.Context, params *BatchSetParams) (*BatchSetResult, error) {
+	result, encodedValues, err := ru.batchSet(ctx, params)
+	if err != nil {
+		ru.getLogger().Errorf(ctx, "CacheUtil.BatchSet, error:%+v", err)
+	}
+
+	for i, data := range encodedValues {
+		if data != nil && result != nil && result.Errors[i] == nil { // 写入 redis 失败的不写入一级缓存
+			ru.localCacheSet(params.Keys[i], data, params.ExpireSecondsSlice[i])
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,160 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalCacheWrapper(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute))
+	otherRedisUtil := NewRedisUtil(getTestPool()) // 没有一级缓存
+	key := "gotest:redis_util:local_cache_wrapper"
+
+	fallbackCount := 0
+	fallbackFunc := func() (interface{}, error) {
+		fallbackCount++
+
+		return "value", nil
+	}
+
+	params := &WrapperParams{Key: key, ExpireSeconds: 600, FallbackFunc: fallbackFunc}
+
+	// 第一次穿透, 写入 redis 和一级缓存
+	result := ""
+	params.Result = &result
+	err := redisUtil.CacheWrapper(ctx, params)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+	assert.Equal(t, 1, fallbackCount)
+	assert.Equal(t, CacheStats{L1Misses: 1, L2Misses: 1}, redisUtil.CacheStats())
+
+	// 其他进程删除 redis 中的数据, 一级缓存仍然命中
+	_ = otherRedisUtil.Del(ctx, key)
+
+	result = ""
+	err = redisUtil.CacheWrapper(ctx, params)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+	assert.Equal(t, 1, fallbackCount)
+	assert.Equal(t, CacheStats{L1Hits: 1, L1Misses: 1, L2Misses: 1}, redisUtil.CacheStats())
+
+	// 当前进程的 Del 同时删除一级缓存
+	_ = otherRedisUtil.Set(ctx, key, "value2", 600)
+	_ = redisUtil.Del(ctx, key)
+	_ = otherRedisUtil.Set(ctx, key, "value2", 600)
+
+	result = ""
+	err = redisUtil.CacheWrapper(ctx, params)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value2", result)
+	assert.Equal(t, CacheStats{L1Hits: 1, L1Misses: 2, L2Hits: 1, L2Misses: 1}, redisUtil.CacheStats())
+
+	_ = redisUtil.Del(ctx, key)
+}
+
+func TestLocalCacheWrapperMget(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute))
+	keys := []string{"gotest:redis_util:local_cache_mget1", "gotest:redis_util:local_cache_mget2"}
+
+	_ = redisUtil.Set(ctx, keys[0], "value0", 600)
+
+	fallbackCount := 0
+	batchFallbackFunc := func(fallbackIndexes []int) (map[int]interface{}, error) {
+		fallbackCount++
+
+		result := make(map[int]interface{})
+		for _, i := range fallbackIndexes {
+			result[i] = "fallback"
+		}
+
+		return result, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		dataResult := make([]string, len(keys))
+
+		err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+			Keys:              keys,
+			ExpireSeconds:     []int{600, 600},
+			ResultSlice:       &dataResult,
+			BatchFallbackFunc: batchFallbackFunc,
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"value0", "fallback"}, dataResult)
+	}
+
+	assert.Equal(t, 1, fallbackCount)
+	assert.Equal(t, CacheStats{L1Hits: 2, L1Misses: 2, L2Hits: 1, L2Misses: 1}, redisUtil.CacheStats())
+
+	for _, key := range keys {
+		_ = redisUtil.Del(ctx, key)
+	}
+}
+
+func TestLocalCacheRemainingTTL(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionLocalCache(100, time.Minute))
+	otherRedisUtil := NewRedisUtil(getTestPool()) // 没有一级缓存
+	keys := []string{"gotest:redis_util:local_cache_ttl1", "gotest:redis_util:local_cache_ttl2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = otherRedisUtil.Del(ctx, key)
+		}
+	}()
+
+	// 其他进程写入的 key 只剩 1 秒过期
+	for _, key := range keys {
+		_ = otherRedisUtil.Set(ctx, key, "old", 1)
+	}
+
+	fallbackFunc := func() (interface{}, error) {
+		return "new", nil
+	}
+
+	batchFallbackFunc := func(fallbackIndexes []int) (map[int]interface{}, error) {
+		result := make(map[int]interface{})
+		for _, i := range fallbackIndexes {
+			result[i] = "new"
+		}
+
+		return result, nil
+	}
+
+	getFunc := func() (string, string) {
+		result := ""
+		err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: keys[0], ExpireSeconds: 600, Result: &result, FallbackFunc: fallbackFunc,
+		})
+		assert.Equal(t, nil, err)
+
+		dataResult := make([]string, 1)
+		err = redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+			Keys: keys[1:], ExpireSeconds: []int{600}, ResultSlice: &dataResult,
+			BatchFallbackFunc: batchFallbackFunc,
+		})
+		assert.Equal(t, nil, err)
+
+		return result, dataResult[0]
+	}
+
+	value, mgetValue := getFunc()
+	assert.Equal(t, "old", value)
+	assert.Equal(t, "old", mgetValue)
+
+	// 一级缓存的过期时间不超过 redis 中剩余的过期时间, 不按 ExpireSeconds
+	time.Sleep(1500 * time.Millisecond)
+
+	value, mgetValue = getFunc()
+	assert.Equal(t, "new", value)
+	assert.Equal(t, "new", mgetValue)
+}
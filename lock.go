@@ -0,0 +1,64 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// 只有持有者(token 一致)才能删除锁
+var releaseLockScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func newLockToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// SET NX PX 加锁, 锁已被占用时返回 false
+func (ru *RedisUtil) acquireLock(ctx context.Context,
+	key string, token string, ttl time.Duration) (locked bool, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		_, err2 := redis.String(conDo(ctx, con, "SET", ru.keyPatch(key), token,
+			"PX", ttl.Milliseconds(), "NX"))
+		if err2 == redis.ErrNil {
+			return nil
+		}
+
+		if err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		locked = true
+
+		return nil
+	})
+
+	return locked, err
+}
+
+// compare-and-delete 释放锁, 锁已过期或被其他人持有时返回 false
+func (ru *RedisUtil) releaseLock(ctx context.Context, key string, token string) (released bool, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		n, err2 := redis.Int(releaseLockScript.DoContext(ctx, con, ru.keyPatch(key), token))
+		if err2 != nil {
+			return errors.WithStack(ctxError(ctx, err2))
+		}
+
+		released = n > 0
+
+		return nil
+	})
+
+	return released, err
+}
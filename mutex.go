@@ -0,0 +1,281 @@
+package redisutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultMutexTTL      = 10 * time.Second
+	DefaultMutexRetryMin = 10 * time.Millisecond
+	DefaultMutexRetryMax = 500 * time.Millisecond
+)
+
+var ErrLockNotHeld = errors.New("lock not held") // 锁已过期或被其他人持有
+
+// MutexParams 分布式锁的参数
+type MutexParams struct {
+	TTL      time.Duration // 锁的租期, 默认 DefaultMutexTTL
+	Watchdog bool          // 持有期间后台每 TTL/3 续期一次, 直到 Unlock 或续期失败
+
+	// Lock 阻塞等待时的重试间隔, 从 RetryMin 开始指数退避到 RetryMax, 默认 DefaultMutexRetryMin, DefaultMutexRetryMax
+	RetryMin time.Duration
+	RetryMax time.Duration
+}
+
+// Mutex 持有中的分布式锁, 通过 RedisUtil.Lock 或 RedisUtil.TryLock 获取
+type Mutex struct {
+	redisUtil    *RedisUtil
+	key          string
+	lockKey      string // 加锁时计算的 redis key, 不受之后 BumpKeyVersion 的影响
+	token        string
+	fencingToken int64
+	ttl          time.Duration
+
+	unlockOnce sync.Once
+	stopCh     chan struct{} // Unlock 时关闭, 停止 watchdog
+	doneCh     chan struct{} // watchdog 退出时关闭
+	lostCh     chan struct{} // 续期失败(锁已丢失)时关闭
+}
+
+// 加锁成功时 INCR fencing key 并返回, 加锁失败返回 0
+// fencing key 不设置过期时间, 保证单调递增
+var acquireMutexScript = redis.NewScript(2, `
+if redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX") then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// 只有持有者才能续期
+var renewMutexScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// 锁和 fencing key 只加命名空间不加 key version, BumpKeyVersion 后仍然是同一把锁, fencing token 也不会重置
+// 两个 key 使用相同的 hash tag, redis cluster 中落在同一个 slot
+func (ru *RedisUtil) mutexKeys(key string) (lockKey string, fencingKey string) {
+	if ru.keyNamespace != "" {
+		key = ru.keyNamespace + ":" + key
+	}
+
+	lockKey = "{" + key + "}"
+
+	return lockKey, lockKey + ":fencing"
+}
+
+func (params *MutexParams) withDefault() MutexParams {
+	newParams := MutexParams{}
+	if params != nil {
+		newParams = *params
+	}
+
+	if newParams.TTL <= 0 {
+		newParams.TTL = DefaultMutexTTL
+	}
+
+	if newParams.RetryMin <= 0 {
+		newParams.RetryMin = DefaultMutexRetryMin
+	}
+
+	if newParams.RetryMax < newParams.RetryMin {
+		newParams.RetryMax = DefaultMutexRetryMax
+	}
+
+	if newParams.RetryMax < newParams.RetryMin {
+		newParams.RetryMax = newParams.RetryMin
+	}
+
+	return newParams
+}
+
+// TryLock 尝试加锁一次, 锁被占用时 locked 为 false
+func (ru *RedisUtil) TryLock(ctx context.Context,
+	key string, params *MutexParams) (mutex *Mutex, locked bool, err error) {
+	newParams := params.withDefault()
+
+	return ru.tryLock(ctx, key, &newParams)
+}
+
+// Lock 阻塞直到加锁成功, ctx 超时或取消时返回 ErrTimeout, ErrCanceled
+func (ru *RedisUtil) Lock(ctx context.Context, key string, params *MutexParams) (*Mutex, error) {
+	newParams := params.withDefault()
+	backoff := newParams.RetryMin
+
+	for {
+		mutex, locked, err := ru.tryLock(ctx, key, &newParams)
+		if err != nil || locked {
+			return mutex, err
+		}
+
+		// 随机等待 [backoff/2, backoff], 避免多个等待者同时重试
+		wait := backoff/2 + time.Duration(ru.rand.intn(int(backoff/2)+1))
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctxError(ctx, ctx.Err())
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > newParams.RetryMax {
+			backoff = newParams.RetryMax
+		}
+	}
+}
+
+func (ru *RedisUtil) tryLock(ctx context.Context,
+	key string, params *MutexParams) (mutex *Mutex, locked bool, err error) {
+	token := newLockToken()
+	lockKey, fencingKey := ru.mutexKeys(key)
+
+	var fencingToken int64
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		fencingToken, err = redis.Int64(acquireMutexScript.DoContext(ctx, con,
+			lockKey, fencingKey, token, params.TTL.Milliseconds()))
+
+		return errors.WithStack(ctxError(ctx, err))
+	})
+
+	if err != nil || fencingToken == 0 {
+		return nil, false, err
+	}
+
+	mutex = &Mutex{
+		redisUtil:    ru,
+		key:          key,
+		lockKey:      lockKey,
+		token:        token,
+		fencingToken: fencingToken,
+		ttl:          params.TTL,
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+		lostCh:       make(chan struct{}),
+	}
+
+	if params.Watchdog {
+		go mutex.watchdog(detachContext(ctx))
+	} else {
+		close(mutex.doneCh)
+	}
+
+	return mutex, true, nil
+}
+
+// FencingToken 加锁时获得的单调递增的 token, 写入下游存储时携带, 由下游拒绝比已见过的更小的 token
+func (m *Mutex) FencingToken() int64 {
+	return m.fencingToken
+}
+
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+// Lost 开启 watchdog 时, 续期发现锁已丢失后关闭
+func (m *Mutex) Lost() <-chan struct{} {
+	return m.lostCh
+}
+
+// Extend 手动续期为 ttl, 锁已丢失时返回 ErrLockNotHeld
+func (m *Mutex) Extend(ctx context.Context, ttl time.Duration) error {
+	ru := m.redisUtil
+
+	var n int
+
+	err := ru.WrapDo(ctx, func(con redis.Conn) error {
+		var err error
+
+		n, err = redis.Int(renewMutexScript.DoContext(ctx, con, m.lockKey, m.token, ttl.Milliseconds()))
+
+		return errors.WithStack(ctxError(ctx, err))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// 每 TTL/3 续期一次, 续期出错时继续重试, 锁已丢失时退出
+func (m *Mutex) watchdog(ctx context.Context) {
+	defer close(m.doneCh)
+
+	interval := m.ttl / 3
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithTimeout(ctx, interval)
+		err := m.Extend(renewCtx, m.ttl)
+
+		cancel()
+
+		if err == ErrLockNotHeld {
+			m.redisUtil.getLogger().Errorf(ctx, "CacheUtil.Mutex.watchdog, key:%s, lock lost", m.key)
+			close(m.lostCh)
+
+			return
+		}
+
+		if err != nil {
+			m.redisUtil.getLogger().Errorf(ctx, "CacheUtil.Mutex.watchdog, key:%s, renew error:%+v", m.key, err)
+		}
+	}
+}
+
+// Unlock 停止续期并释放锁, 锁已过期或被其他人持有时返回 ErrLockNotHeld, 重复 Unlock 也返回 ErrLockNotHeld
+func (m *Mutex) Unlock(ctx context.Context) error {
+	unlocked := false
+
+	m.unlockOnce.Do(func() {
+		unlocked = true
+
+		close(m.stopCh)
+		<-m.doneCh
+	})
+
+	if !unlocked {
+		return ErrLockNotHeld
+	}
+
+	var n int
+
+	err := m.redisUtil.WrapDo(ctx, func(con redis.Conn) error {
+		var err error
+
+		n, err = redis.Int(releaseLockScript.DoContext(ctx, con, m.lockKey, m.token))
+
+		return errors.WithStack(ctxError(ctx, err))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
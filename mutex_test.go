@@ -0,0 +1,191 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutex(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:mutex"
+
+	lockKey, fencingKey := redisUtil.mutexKeys(key)
+
+	defer func() {
+		_ = redisUtil.Del(ctx, lockKey)
+		_ = redisUtil.Del(ctx, fencingKey)
+	}()
+
+	mutex1, locked, err := redisUtil.TryLock(ctx, key, nil)
+	assert.Equal(t, nil, err)
+	assert.True(t, locked)
+
+	_, locked, err = redisUtil.TryLock(ctx, key, nil)
+	assert.Equal(t, nil, err)
+	assert.False(t, locked)
+
+	// 被占用时阻塞到 ctx 超时
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = redisUtil.Lock(timeoutCtx, key, nil)
+	cancel()
+	assert.True(t, errors.Is(err, ErrTimeout))
+
+	err = mutex1.Unlock(ctx)
+	assert.Equal(t, nil, err)
+
+	err = mutex1.Unlock(ctx)
+	assert.Equal(t, ErrLockNotHeld, err)
+
+	mutex2, err := redisUtil.Lock(ctx, key, nil)
+	assert.Equal(t, nil, err)
+	assert.True(t, mutex2.FencingToken() > mutex1.FencingToken())
+
+	// 锁过期后被其他人持有, 不能删除其他人的锁
+	mutex3, _ := redisUtil.Lock(ctx, key+":expire", &MutexParams{TTL: 100 * time.Millisecond})
+	time.Sleep(200 * time.Millisecond)
+
+	mutex4, locked, _ := redisUtil.TryLock(ctx, key+":expire", nil)
+	assert.True(t, locked)
+	assert.Equal(t, ErrLockNotHeld, mutex3.Unlock(ctx))
+	assert.Equal(t, nil, mutex4.Unlock(ctx))
+	assert.Equal(t, nil, mutex2.Unlock(ctx))
+
+	expireLockKey, expireFencingKey := redisUtil.mutexKeys(key + ":expire")
+	_ = redisUtil.Del(ctx, expireLockKey)
+	_ = redisUtil.Del(ctx, expireFencingKey)
+}
+
+func TestMutexWatchdog(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:mutex_watchdog"
+
+	lockKey, fencingKey := redisUtil.mutexKeys(key)
+
+	defer func() {
+		_ = redisUtil.Del(ctx, lockKey)
+		_ = redisUtil.Del(ctx, fencingKey)
+	}()
+
+	mutex, err := redisUtil.Lock(ctx, key, &MutexParams{TTL: 300 * time.Millisecond, Watchdog: true})
+	assert.Equal(t, nil, err)
+
+	// 超过 TTL 后仍然持有
+	time.Sleep(time.Second)
+
+	_, locked, _ := redisUtil.TryLock(ctx, key, nil)
+	assert.False(t, locked)
+
+	// 锁被删除后 watchdog 发现锁已丢失
+	_ = redisUtil.Del(ctx, lockKey)
+
+	select {
+	case <-mutex.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost not closed")
+	}
+
+	assert.Equal(t, ErrLockNotHeld, mutex.Unlock(ctx))
+}
+
+func TestMutexConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:mutex_concurrent"
+
+	lockKey, fencingKey := redisUtil.mutexKeys(key)
+
+	defer func() {
+		_ = redisUtil.Del(ctx, lockKey)
+		_ = redisUtil.Del(ctx, fencingKey)
+	}()
+
+	var wg sync.WaitGroup
+
+	var mu sync.Mutex
+
+	holding := 0
+	maxHolding := 0
+	fencingTokens := make(map[int64]bool)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			mutex, err := redisUtil.Lock(ctx, key, &MutexParams{RetryMax: 20 * time.Millisecond})
+			assert.Equal(t, nil, err)
+
+			mu.Lock()
+			holding++
+			if holding > maxHolding {
+				maxHolding = holding
+			}
+			fencingTokens[mutex.FencingToken()] = true
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			holding--
+			mu.Unlock()
+
+			assert.Equal(t, nil, mutex.Unlock(ctx))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, maxHolding)
+	assert.Equal(t, 10, len(fencingTokens))
+}
+
+// 锁不受 BumpKeyVersion 影响, 持有中的锁仍然互斥, 可以续期和释放, fencing token 不会重置
+func TestMutexKeyVersion(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := "gotest_mutex_key_version"
+	key := "gotest:redis_util:mutex_key_version"
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 1))
+	defer redisUtil.Close()
+
+	lockKey, fencingKey := redisUtil.mutexKeys(key)
+	assert.Equal(t, "{"+namespace+":"+key+"}", lockKey)
+
+	defer func() {
+		rawRedisUtil := NewRedisUtil(getTestPool())
+		_ = rawRedisUtil.Del(ctx, lockKey)
+		_ = rawRedisUtil.Del(ctx, fencingKey)
+		_ = rawRedisUtil.Del(ctx, namespace+":keyversion")
+	}()
+
+	mutex1, err := redisUtil.Lock(ctx, key, nil)
+	assert.Equal(t, nil, err)
+
+	_, err = redisUtil.BumpKeyVersion(ctx)
+	assert.Equal(t, nil, err)
+
+	_, locked, err := redisUtil.TryLock(ctx, key, nil)
+	assert.Equal(t, nil, err)
+	assert.False(t, locked)
+
+	assert.Equal(t, nil, mutex1.Extend(ctx, time.Second))
+	assert.Equal(t, nil, mutex1.Unlock(ctx))
+
+	mutex2, err := redisUtil.Lock(ctx, key, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, mutex1.FencingToken()+1, mutex2.FencingToken())
+	assert.Equal(t, nil, mutex2.Unlock(ctx))
+}
@@ -1,5 +1,11 @@
 package redisutil
 
+import (
+	"time"
+
+	"github.com/cclehui/redisutil/internal/localcache"
+)
+
 type Option interface {
 	Apply(*RedisUtil)
 }
@@ -21,3 +27,100 @@ func OptionLogger(logger Logger) Option {
 		cacheUtil.logger = logger
 	})
 }
+
+// 默认的缓存值 codec, 不设置时使用 GobCodec
+func OptionCodec(codec Codec) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.codec = codec
+	})
+}
+
+// 写入缓存时带上信封 (codec id, schema version),
+// 读取时 schema version 不一致当作缓存未命中, 结构变更时升级 schemaVersion 即可, 无需清理缓存
+func OptionEnvelope(schemaVersion uint32) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.envelope = true
+		cacheUtil.schemaVersion = schemaVersion
+	})
+}
+
+// 编码后超过 thresholdBytes 的值使用 compressor 压缩, 读取时自动解压
+func OptionCompress(compressor Compressor, thresholdBytes int) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.compressor = compressor
+		cacheUtil.compressThreshold = thresholdBytes
+	})
+}
+
+// 所有 key 加上 "namespace:v{version}:" 前缀, 通过 BumpKeyVersion 升级版本可让所有 key 失效
+// NewRedisUtil 时阻塞读取一次 redis 中的版本(最长 1 秒), redis 中的版本更大时使用 redis 中的版本
+// 之后的同步需要开启 OptionKeyVersionSync 或 OptionInvalidation
+func OptionKeyNamespace(namespace string, version int64) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.keyNamespace = namespace
+		cacheUtil.keyVersion = version
+	})
+}
+
+// 后台每 interval 从 redis 同步一次 key 版本, 默认不开启, 只在创建时同步一次
+// 开启后不再使用时需要调用 RedisUtil.Close 停止后台同步
+func OptionKeyVersionSync(interval time.Duration) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.keyVersionSyncInterval = interval
+	})
+}
+
+// CacheWrapper, CacheWrapperMget 在 redis 前面增加进程内 LRU 缓存,
+// 最多 maxEntries 条, 每条最长缓存 maxTTL (不超过 redis 的过期时间)
+func OptionLocalCache(maxEntries int, maxTTL time.Duration) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.localCache = localcache.NewLRU(maxEntries, maxTTL)
+	})
+}
+
+// 通过 redis pub/sub 的 channel 广播一级缓存失效通知和 key 版本升级,
+// Set, BatchSet, Del 和 FlushCache 的 wrapper 会发送通知, 开启了一级缓存的进程会订阅并删除对应的 key
+// 设置了 OptionKeyNamespace 的进程会订阅并同步 BumpKeyVersion 升级后的版本
+// 不再使用时需要调用 RedisUtil.Close 停止订阅
+func OptionInvalidation(channel string) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.invalidationChannel = channel
+	})
+}
+
+func OptionBatchSetFailedHook(hook BatchSetFailedHook) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.batchSetFailedHook = hook
+	})
+}
+
+// 跨进程 singleflight 的锁过期时间和最长等待时间, 锁过期时间应大于 fallback 的耗时
+func OptionDistributedSingleFlight(lockTTL, waitTimeout time.Duration) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.distributedLockTTL = lockTTL
+		cacheUtil.distributedLockWait = waitTimeout
+	})
+}
+
+// 默认的过期时间随机延长, 在 Set, BatchSet, CacheWrapper, CacheWrapperMget 中生效, 可以单次覆盖
+func OptionTTLJitter(jitter TTLJitter) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.ttlJitter = &jitter
+	})
+}
+
+// 固定随机数种子, 用于测试中得到确定的 ttl 随机延长和提前重新计算结果
+func OptionRandSeed(seed int64) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		cacheUtil.rand = newLockedRand(seed)
+	})
+}
+
+// 限制当前 RedisUtil 同时执行的 fallback 总数, 超过时直接返回 ErrFallbackOverload
+func OptionFallbackLimit(maxInFlight int) Option {
+	return OptionFunc(func(cacheUtil *RedisUtil) {
+		if maxInFlight > 0 {
+			cacheUtil.fallbackSem = make(chan struct{}, maxInFlight)
+		}
+	})
+}
@@ -4,50 +4,97 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
-	"github.com/cclehui/redisutil/internal/base"
+	"github.com/cclehui/redisutil/internal/localcache"
 	"github.com/pkg/errors"
 
 	"github.com/gomodule/redigo/redis"
 )
 
 type RedisUtil struct {
+	keyVersion int64 // 原子操作, 放在第一个字段保证64位对齐
+
 	pool *redis.Pool
 
 	singleFlightGroupNum int
 
 	logger Logger
+	codec  Codec
+
+	envelope      bool   // 是否写入信封
+	schemaVersion uint32 // 信封中的结构版本
+
+	compressor        Compressor
+	compressThreshold int // 超过该字节数的值才压缩
+
+	keyNamespace           string        // key 前缀
+	keyVersionSyncInterval time.Duration // 后台同步 key 版本的间隔, 为0 时不开启
+
+	localCache *localcache.LRU // 进程内一级缓存, 只在 CacheWrapper 中使用
+	stats      *cacheStats
+
+	rand      *lockedRand
+	ttlJitter *TTLJitter // 默认的过期时间随机延长
+
+	invalidationChannel string // 一级缓存失效通知的 pub/sub channel
+	instanceID          string
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	closeWG   sync.WaitGroup
+
+	batchSetFailedHook BatchSetFailedHook
+
+	distributedLockTTL  time.Duration // 跨进程 singleflight 锁的过期时间
+	distributedLockWait time.Duration // 跨进程 singleflight 最长等待时间
+
+	fallbackSem chan struct{} // 限制同时执行的 fallback 数量
 }
 
 func NewRedisUtil(pool *redis.Pool, options ...Option) *RedisUtil {
-	result := &RedisUtil{pool: pool}
+	result := &RedisUtil{
+		pool:       pool,
+		stats:      &cacheStats{},
+		instanceID: newInstanceID(),
+		closeCh:    make(chan struct{}),
+		rand:       newLockedRand(time.Now().UnixNano()),
+	}
 
 	for _, option := range options {
 		option.Apply(result)
 	}
 
+	result.startKeyVersionSync()
+	result.startInvalidationSubscriber()
+
 	return result
 }
 
 func (ru *RedisUtil) Set(ctx context.Context, key string, value interface{}, ttl int) (err error) {
-	// 判断是否整数
-	var bytesData []byte
-
-	if s, ok := isNum(value); ok {
-		bytesData = []byte(s)
-	} else {
-		bytesData, err = base.Encode(value)
+	return ru.set(ctx, key, value, ttl, ru.getValueCodec(nil))
+}
 
-		if err != nil {
-			return err
-		}
+func (ru *RedisUtil) set(ctx context.Context, key string, value interface{}, ttl int, vc *valueCodec) (err error) {
+	bytesData, err := vc.encode(value)
+	if err != nil {
+		return err
 	}
 
+	err = ru.setBytes(ctx, key, bytesData, ru.jitterTTL(ttl, nil))
+
+	ru.invalidate(ctx, key)
+
+	return err
+}
+
+func (ru *RedisUtil) setBytes(ctx context.Context, key string, bytesData []byte, ttl int) (err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
 		if ttl == TTLNoExpire { // 不过期
-			_, err = conDo(ctx, con, "SET", keyPatch(key), bytesData)
+			_, err = conDo(ctx, con, "SET", ru.keyPatch(key), bytesData)
 		} else {
-			_, err = conDo(ctx, con, "SET", keyPatch(key), bytesData, "EX", ttl)
+			_, err = conDo(ctx, con, "SET", ru.keyPatch(key), bytesData, "EX", ttl)
 		}
 
 		if err != nil {
@@ -68,59 +115,167 @@ type BatchSetParams struct {
 	Keys               []string
 	Values             []interface{}
 	ExpireSecondsSlice []int
+
+	Codec Codec // 为空时使用默认 codec
+
+	TTLJitter *TTLJitter // 过期时间随机延长, 为空时使用 OptionTTLJitter 的配置, 零值表示不延长
+
+	metas []*valueMeta // 和 Keys 一一对应, CacheWrapperMget 内部使用
+}
+
+var ErrBatchSetPartialFailed = errors.New("batch set partial failed")
+
+// BatchSetResult 每个key 的写入结果, Errors 和 Keys 一一对应, nil 表示写入成功
+type BatchSetResult struct {
+	Keys   []string
+	Errors []error
+}
+
+func (bsr *BatchSetResult) SuccessKeys() []string {
+	result := make([]string, 0, len(bsr.Keys))
+
+	for i, key := range bsr.Keys {
+		if bsr.Errors[i] == nil {
+			result = append(result, key)
+		}
+	}
+
+	return result
+}
+
+func (bsr *BatchSetResult) FailedKeys() []string {
+	result := make([]string, 0)
+
+	for i, key := range bsr.Keys {
+		if bsr.Errors[i] != nil {
+			result = append(result, key)
+		}
+	}
+
+	return result
 }
 
-func (ru *RedisUtil) BatchSet(ctx context.Context, params *BatchSetParams) (err error) {
+// Err 汇总的错误, 全部成功时为nil
+func (bsr *BatchSetResult) Err() error {
+	for _, err := range bsr.Errors {
+		if err != nil {
+			return errors.WithMessagef(ErrBatchSetPartialFailed,
+				"failed keys:%v, first error:%v", bsr.FailedKeys(), err)
+		}
+	}
+
+	return nil
+}
+
+// BatchSet pipeline 批量写入, 单个key 失败不影响其他key, 通过 BatchSetResult 获取每个key 的结果
+func (ru *RedisUtil) BatchSet(ctx context.Context, params *BatchSetParams) (result *BatchSetResult, err error) {
 	defer func() {
 		if err != nil {
 			ru.getLogger().Errorf(ctx, "CacheUtil.BatchSet, error:%+v", err)
 		}
 	}()
 
+	result, _, err = ru.batchSet(ctx, params)
+
+	ru.invalidate(ctx, params.Keys...)
+
+	return result, err
+}
+
+// encodedValues 为编码后的值, 编码失败的为nil
+// 不发送一级缓存失效通知, 缓存回写时由调用方决定是否通知, 避免每次回写都清空其他进程的一级缓存
+func (ru *RedisUtil) batchSet(ctx context.Context,
+	params *BatchSetParams) (result *BatchSetResult, encodedValues [][]byte, err error) {
 	if len(params.Keys) != len(params.Values) ||
 		len(params.Keys) != len(params.ExpireSecondsSlice) {
-		return errors.New("Keys Values ExpireSecondsSlice length is not equal")
+		return nil, nil, errors.New("Keys Values ExpireSecondsSlice length is not equal")
+	}
+
+	encodedValues = make([][]byte, len(params.Keys))
+
+	result = &BatchSetResult{
+		Keys:   params.Keys,
+		Errors: make([]error, len(params.Keys)),
 	}
 
+	vc := ru.getValueCodec(params.Codec)
+
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		sentIndexes := make([]int, 0, len(params.Keys)) // 已发送命令的key
+
 		for i, key := range params.Keys {
 			value := params.Values[i]
-			expireSeconds := params.ExpireSecondsSlice[i]
+			expireSeconds := ru.jitterTTL(params.ExpireSecondsSlice[i], params.TTLJitter)
+
+			var meta *valueMeta
+			if params.metas != nil {
+				meta = params.metas[i]
+			}
 
-			bytesData, err2 := base.Encode(value)
+			bytesData, err2 := vc.encodeWithMeta(value, meta)
 			if err2 != nil {
-				return err2
+				result.Errors[i] = err2
+				continue
 			}
 
+			encodedValues[i] = bytesData
+
 			if expireSeconds == TTLNoExpire { // 不过期
-				err = conSend(ctx, con, "SET", keyPatch(key), bytesData)
+				err2 = conSend(ctx, con, "SET", ru.keyPatch(key), bytesData)
 			} else {
-				err = conSend(ctx, con, "SET", keyPatch(key), bytesData, "EX", expireSeconds)
+				err2 = conSend(ctx, con, "SET", ru.keyPatch(key), bytesData, "EX", expireSeconds)
 			}
 
-			if err != nil {
-				return errors.WithStack(err)
+			if err2 != nil { // 连接异常, 后续命令也无法发送
+				return errors.WithStack(err2)
 			}
+
+			sentIndexes = append(sentIndexes, i)
 		}
 
-		err = conFlush(ctx, con)
-		if err != nil {
-			return errors.WithStack(err)
+		if err2 := conFlush(ctx, con); err2 != nil {
+			return errors.WithStack(err2)
 		}
 
-		for i := 0; i < len(params.Keys); i++ {
-			if _, err = conReceive(ctx, con); err != nil {
-				return errors.WithStack(err)
+		// 读取全部回复, 避免连接中残留未读取的数据
+		for j, index := range sentIndexes {
+			_, err2 := conReceive(ctx, con)
+			if err2 == nil {
+				continue
 			}
+
+			if !isRedisReplyError(err2) { // 连接异常, 剩余的回复已无法读取
+				for _, leftIndex := range sentIndexes[j:] {
+					result.Errors[leftIndex] = errors.WithStack(err2)
+				}
+
+				break
+			}
+
+			result.Errors[index] = errors.WithStack(err2)
 		}
 
 		return nil
 	})
 
-	return nil
+	if err != nil { // 整体失败
+		for i := range result.Errors {
+			if result.Errors[i] == nil {
+				result.Errors[i] = err
+			}
+		}
+
+		return result, encodedValues, err
+	}
+
+	return result, encodedValues, result.Err()
 }
 
 func (ru *RedisUtil) Get(ctx context.Context, key string, value interface{}) (hit bool, err error) {
+	return ru.get(ctx, key, value, ru.getValueCodec(nil))
+}
+
+func (ru *RedisUtil) get(ctx context.Context, key string, value interface{}, vc *valueCodec) (hit bool, err error) {
 	defer func() {
 		if err != nil {
 			ru.getLogger().Errorf(ctx, "CacheUtil.GetCache, error:%+v", errors.WithStack(err))
@@ -131,10 +286,18 @@ func (ru *RedisUtil) Get(ctx context.Context, key string, value interface{}) (hi
 		return false, errors.New("value must be ptr")
 	}
 
-	var replay []byte
+	replay, err := ru.getBytes(ctx, key)
+	if err != nil || replay == nil {
+		return false, err
+	}
 
+	return vc.decode(replay, value)
+}
+
+// key 不存在时返回 nil, nil
+func (ru *RedisUtil) getBytes(ctx context.Context, key string) (replay []byte, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		replay, err = redis.Bytes(conDo(ctx, con, "GET", keyPatch(key)))
+		replay, err = redis.Bytes(conDo(ctx, con, "GET", ru.keyPatch(key)))
 
 		if err != nil {
 			return err
@@ -144,54 +307,98 @@ func (ru *RedisUtil) Get(ctx context.Context, key string, value interface{}) (hi
 	})
 
 	if err == redis.ErrNil {
-		return false, nil
+		return nil, nil
 	}
 
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	if isNumPtr(value) { // 数字
-		err = bytesToNum(replay, value)
-	} else {
-		err = base.Decode(replay, value)
-	}
+	return replay, nil
+}
 
-	if err != nil {
-		return false, err
-	}
+// GET 的同时获取剩余的过期时间(PTTL 毫秒, 不过期为 -1), 用于写入一级缓存
+func (ru *RedisUtil) getBytesWithPTTL(ctx context.Context, key string) (replay []byte, pttl int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		patchedKey := ru.keyPatch(key)
+
+		if err2 := conSend(ctx, con, "GET", patchedKey); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conSend(ctx, con, "PTTL", patchedKey); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conFlush(ctx, con); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		// 先读取全部回复, 避免连接中残留未读取的数据
+		getReply, getErr := conReceive(ctx, con)
+
+		pttl, err = redis.Int64(conReceive(ctx, con))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		replay, err = redis.Bytes(getReply, getErr)
+		if err == redis.ErrNil {
+			replay = nil
+			return nil
+		}
+
+		return errors.WithStack(err)
+	})
 
-	return true, nil
+	return replay, pttl, err
 }
 
 func (ru *RedisUtil) MGet(ctx context.Context,
 	keys []string, valuesInter interface{}) (hits []bool, err error) {
+	return ru.mget(ctx, keys, valuesInter, ru.getValueCodec(nil))
+}
+
+func (ru *RedisUtil) mget(ctx context.Context,
+	keys []string, valuesInter interface{}, vc *valueCodec) (hits []bool, err error) {
 	defer func() {
 		if err != nil {
 			ru.getLogger().Errorf(ctx, "CacheUtil.MGet, error:%+v", err)
 		}
 	}()
 
-	valuesInterRF := reflect.ValueOf(valuesInter)
-
-	if valuesInterRF.Kind() != reflect.Ptr {
-		return nil, errors.New(fmt.Sprintf("valuesInter is not ptr: %+v", valuesInter))
+	valuesInterRFElem, err := sliceResultValue(keys, valuesInter)
+	if err != nil {
+		return nil, err
 	}
 
-	valuesInterRFElem := valuesInterRF.Elem()
-	if valuesInterRFElem.Kind() != reflect.Slice {
-		return nil, errors.New("valuesInterRFElem[%d] is not slice")
+	redisResult, err := ru.mgetBytes(ctx, keys)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(keys) != valuesInterRFElem.Len() {
-		return nil, errors.New("keys and values length must equal")
+	hitResult := make([]bool, len(keys))
+
+	for i, dbBytes := range redisResult {
+		if dbBytes == nil {
+			hitResult[i] = false
+			continue
+		}
+
+		hitResult[i], err = vc.decode(dbBytes, valuesInterRFElem.Index(i).Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var redisResult [][]byte
+	return hitResult, nil
+}
 
+// 没有命中的 key 结果为 nil
+func (ru *RedisUtil) mgetBytes(ctx context.Context, keys []string) (redisResult [][]byte, err error) {
 	// mget 没有命中key的情况下err 也是nil
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		redisResult, err = redis.ByteSlices(conDo(ctx, con, "MGET", keysPatch(keys)...))
+		redisResult, err = redis.ByteSlices(conDo(ctx, con, "MGET", ru.keysPatch(keys)...))
 
 		if err != nil {
 			return errors.WithStack(err)
@@ -200,42 +407,81 @@ func (ru *RedisUtil) MGet(ctx context.Context,
 		return nil
 	})
 
-	hitResult := make([]bool, len(keys))
+	return redisResult, err
+}
 
-	if err != nil {
-		return nil, err
-	}
+// MGET 的同时获取每个 key 剩余的过期时间, 同 getBytesWithPTTL
+func (ru *RedisUtil) mgetBytesWithPTTL(ctx context.Context,
+	keys []string) (redisResult [][]byte, pttls []int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		patchedKeys := ru.keysPatch(keys)
 
-	for i, dbBytes := range redisResult {
-		if dbBytes == nil {
-			hitResult[i] = false
-			continue
+		if err2 := conSend(ctx, con, "MGET", patchedKeys...); err2 != nil {
+			return errors.WithStack(err2)
 		}
 
-		err = base.DecodeReflectValue(dbBytes, valuesInterRFElem.Index(i))
-		if err != nil {
-			return nil, err
+		for _, patchedKey := range patchedKeys {
+			if err2 := conSend(ctx, con, "PTTL", patchedKey); err2 != nil {
+				return errors.WithStack(err2)
+			}
 		}
 
-		hitResult[i] = true
+		if err2 := conFlush(ctx, con); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		mgetReply, mgetErr := conReceive(ctx, con)
+
+		pttls = make([]int64, len(keys))
+		for i := range pttls {
+			if pttls[i], err = redis.Int64(conReceive(ctx, con)); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		redisResult, err = redis.ByteSlices(mgetReply, mgetErr)
+
+		return errors.WithStack(err)
+	})
+
+	return redisResult, pttls, err
+}
+
+// valuesInter 必须是和 keys 等长的 slice 的指针
+func sliceResultValue(keys []string, valuesInter interface{}) (reflect.Value, error) {
+	valuesInterRF := reflect.ValueOf(valuesInter)
+
+	if valuesInterRF.Kind() != reflect.Ptr {
+		return reflect.Value{}, errors.New(fmt.Sprintf("valuesInter is not ptr: %+v", valuesInter))
 	}
 
-	return hitResult, nil
+	valuesInterRFElem := valuesInterRF.Elem()
+	if valuesInterRFElem.Kind() != reflect.Slice {
+		return reflect.Value{}, errors.New("valuesInterRFElem[%d] is not slice")
+	}
+
+	if len(keys) != valuesInterRFElem.Len() {
+		return reflect.Value{}, errors.New("keys and values length must equal")
+	}
+
+	return valuesInterRFElem, nil
 }
 
 func (ru *RedisUtil) Del(ctx context.Context, key string) (err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		_, err = conDo(ctx, con, "DEL", keyPatch(key))
+		_, err = conDo(ctx, con, "DEL", ru.keyPatch(key))
 
 		return err
 	})
 
+	ru.invalidate(ctx, key)
+
 	return err
 }
 
 func (ru *RedisUtil) Expire(ctx context.Context, key string, ttl int) (err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		_, err = conDo(ctx, con, "EXPIRE", keyPatch(key), ttl)
+		_, err = conDo(ctx, con, "EXPIRE", ru.keyPatch(key), ttl)
 
 		return err
 	})
@@ -245,7 +491,7 @@ func (ru *RedisUtil) Expire(ctx context.Context, key string, ttl int) (err error
 
 func (ru *RedisUtil) TTL(ctx context.Context, key string) (ttl int, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		ttl, err = redis.Int(conDo(ctx, con, "TTL", keyPatch(key)))
+		ttl, err = redis.Int(conDo(ctx, con, "TTL", ru.keyPatch(key)))
 
 		return err
 	})
@@ -255,7 +501,7 @@ func (ru *RedisUtil) TTL(ctx context.Context, key string) (ttl int, err error) {
 
 func (ru *RedisUtil) Incr(ctx context.Context, key string) (res int64, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		res, err = redis.Int64(conDo(ctx, con, "INCR", keyPatch(key)))
+		res, err = redis.Int64(conDo(ctx, con, "INCR", ru.keyPatch(key)))
 
 		return err
 	})
@@ -265,7 +511,7 @@ func (ru *RedisUtil) Incr(ctx context.Context, key string) (res int64, err error
 
 func (ru *RedisUtil) IncrBy(ctx context.Context, key string, diff int64) (res int64, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		res, err = redis.Int64(conDo(ctx, con, "INCRBY", keyPatch(key), diff))
+		res, err = redis.Int64(conDo(ctx, con, "INCRBY", ru.keyPatch(key), diff))
 
 		return err
 	})
@@ -275,7 +521,7 @@ func (ru *RedisUtil) IncrBy(ctx context.Context, key string, diff int64) (res in
 
 func (ru *RedisUtil) Decr(ctx context.Context, key string) (res int64, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		res, err = redis.Int64(conDo(ctx, con, "DECR", keyPatch(key)))
+		res, err = redis.Int64(conDo(ctx, con, "DECR", ru.keyPatch(key)))
 
 		return err
 	})
@@ -285,7 +531,7 @@ func (ru *RedisUtil) Decr(ctx context.Context, key string) (res int64, err error
 
 func (ru *RedisUtil) DecrBy(ctx context.Context, key string, diff int64) (res int64, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		res, err = redis.Int64(conDo(ctx, con, "DECRBY", keyPatch(key), diff))
+		res, err = redis.Int64(conDo(ctx, con, "DECRBY", ru.keyPatch(key), diff))
 
 		return err
 	})
@@ -294,13 +540,13 @@ func (ru *RedisUtil) DecrBy(ctx context.Context, key string, diff int64) (res in
 }
 
 type SortSetInfo struct {
-	Score int64
+	Score float64
 	Name  string
 }
 
 func (ru *RedisUtil) ZAdd(ctx context.Context, key string, infos []*SortSetInfo) (err error) {
 	args := make([]interface{}, 0)
-	args = append(args, keyPatch(key))
+	args = append(args, ru.keyPatch(key))
 
 	for _, item := range infos {
 		args = append(args, item.Score, item.Name)
@@ -325,7 +571,7 @@ func (ru *RedisUtil) ZAdd(ctx context.Context, key string, infos []*SortSetInfo)
 
 func (ru *RedisUtil) ZCard(ctx context.Context, key string) (res int64, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		res, err = redis.Int64(conDo(ctx, con, "ZCARD", keyPatch(key)))
+		res, err = redis.Int64(conDo(ctx, con, "ZCARD", ru.keyPatch(key)))
 
 		return err
 	})
@@ -335,7 +581,7 @@ func (ru *RedisUtil) ZCard(ctx context.Context, key string) (res int64, err erro
 
 func (ru *RedisUtil) ZRange(ctx context.Context, key string, start, end int) (result []string, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		result, err = redis.Strings(conDo(ctx, con, "ZRANGE", keyPatch(key), start, end))
+		result, err = redis.Strings(conDo(ctx, con, "ZRANGE", ru.keyPatch(key), start, end))
 
 		if err != nil {
 			return err
@@ -353,7 +599,7 @@ func (ru *RedisUtil) ZRange(ctx context.Context, key string, start, end int) (re
 
 func (ru *RedisUtil) ZRevRange(ctx context.Context, key string, start, end int) (result []string, err error) {
 	err = ru.WrapDo(ctx, func(con redis.Conn) error {
-		result, err = redis.Strings(conDo(ctx, con, "ZREVRANGE", keyPatch(key), start, end))
+		result, err = redis.Strings(conDo(ctx, con, "ZREVRANGE", ru.keyPatch(key), start, end))
 
 		if err != nil {
 			return err
@@ -371,7 +617,7 @@ func (ru *RedisUtil) ZRevRange(ctx context.Context, key string, start, end int)
 
 func (ru *RedisUtil) ZRem(ctx context.Context, key string, names []string) (err error) {
 	args := make([]interface{}, 0)
-	args = append(args, keyPatch(key))
+	args = append(args, ru.keyPatch(key))
 
 	for _, item := range names {
 		args = append(args, item)
@@ -394,8 +640,12 @@ func (ru *RedisUtil) ZRem(ctx context.Context, key string, names []string) (err
 	return nil
 }
 
+// WrapDo 从连接池获取连接并执行 doFunction, 获取连接和执行命令都受 ctx 的超时和取消控制
 func (ru *RedisUtil) WrapDo(ctx context.Context, doFunction func(con redis.Conn) error) error {
-	con := ru.pool.Get()
+	con, err := ru.pool.GetContext(ctx)
+	if err != nil {
+		return errors.WithStack(ctxError(ctx, err))
+	}
 	defer con.Close()
 
 	return doFunction(con)
@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"strings"
+	"time"
 
 	"github.com/cclehui/redisutil/internal/base"
 	"github.com/cclehui/redisutil/internal/singleflight"
@@ -12,6 +12,14 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// FallbackFunc 返回 ErrNotFound 时缓存不存在的标记, 命中该标记时 CacheWrapper 同样返回 ErrNotFound
+var ErrNotFound = errors.New("not found")
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// WrapperParams 每次调用单独使用, 输出字段(Stale)在调用开始时重置并写回 params, 不能在并发的调用间共享
 type WrapperParams struct {
 	Key           string
 	ExpireSeconds int
@@ -20,12 +28,62 @@ type WrapperParams struct {
 
 	SingleFlight bool // 是否启动 singleflight
 	FlushCache   bool // 是否用SetFunc刷新缓存
+
+	Codec Codec // 为空时使用默认 codec
+
+	// stale-while-revalidate, 大于0 且小于 ExpireSeconds 时开启
+	// 超过 SoftExpireSeconds 后直接返回旧值并在后台刷新缓存, 超过 ExpireSeconds 后才会阻塞调用 FallbackFunc
+	SoftExpireSeconds int
+
+	// XFetch 概率提前重新计算, 大于0 时开启, 一般取 1.0, 越大越倾向于提前计算
+	// 缓存中记录 FallbackFunc 的耗时, 越接近过期时间越可能被当作未命中, 避免所有进程同时穿透
+	EarlyRecomputeBeta float64
+
+	// 跨进程 singleflight, 通过 redis 锁保证同一时间只有一个进程调用 FallbackFunc
+	// 其他进程等待缓存写入, 等待超时后直接调用 FallbackFunc, FlushCache 时不生效
+	DistributedSingleFlight bool
+
+	// FallbackFunc 返回 ErrNotFound 时不存在标记的过期时间, 默认 DefaultNotFoundExpireSeconds, 不超过 ExpireSeconds
+	NotFoundExpireSeconds int
+
+	TTLJitter *TTLJitter // 过期时间随机延长, 为空时使用 OptionTTLJitter 的配置, 零值表示不延长
+
+	// stale-if-error, 大于0 时开启, redis 中的值多保留 StaleIfErrorSeconds 秒
+	// 超过 ExpireSeconds 后 FallbackFunc 失败时返回旧值, 同时 Stale 为 true
+	StaleIfErrorSeconds int
+
+	FallbackTimeout time.Duration // FallbackFunc 的超时时间, 为0 时只受 ctx 限制, 超时返回 ErrFallbackTimeout
+
+	Stale bool // 输出, 返回的是逻辑过期的旧值, 每次调用时重置
+}
+
+func (params *WrapperParams) staleWhileRevalidate() bool {
+	return params.SoftExpireSeconds > 0 &&
+		(params.ExpireSeconds == TTLNoExpire || params.SoftExpireSeconds < params.ExpireSeconds)
 }
 
 func (ru *RedisUtil) CacheWrapper(ctx context.Context,
 	params *WrapperParams) (err error) {
+	params.Stale = false
+
+	var staleMeta *valueMeta // 需要重新计算但可以在 FallbackFunc 失败时返回的旧值
+
 	if !params.FlushCache {
-		if hit, _ := ru.Get(ctx, params.Key, params.Result); hit {
+		hit, meta, _ := ru.tieredGet(ctx, params.Key, params.Result, ru.getValueCodec(params.Codec))
+
+		if hit && !meta.notFound {
+			staleMeta = meta
+		}
+
+		if hit && !meta.expired() && !meta.earlyExpired(params.EarlyRecomputeBeta, ru.rand.float64) {
+			if meta.notFound {
+				return ErrNotFound
+			}
+
+			if params.staleWhileRevalidate() && meta.softExpired() {
+				ru.cWrapperRefreshInBackground(ctx, params)
+			}
+
 			return nil
 		}
 	} else if reflect.ValueOf(params.Result).Kind() != reflect.Ptr {
@@ -36,34 +94,92 @@ func (ru *RedisUtil) CacheWrapper(ctx context.Context,
 
 	if params.SingleFlight {
 		newData, err, _ = ru.singleflightGroup(params.Key).Do(params.Key, func() (interface{}, error) {
-			return ru.cWrapperCallAndSetCache(ctx, params)
+			return ru.cWrapperFallback(ctx, params)
 		})
 	} else {
-		newData, err = ru.cWrapperCallAndSetCache(ctx, params)
+		newData, err = ru.cWrapperFallback(ctx, params)
+	}
+
+	if err != nil && !IsNotFound(err) && params.StaleIfErrorSeconds > 0 && staleMeta != nil {
+		ru.getLogger().Errorf(ctx, "CacheUtil.CacheWrapper, key:%s, fallback error, return stale value, error:%+v",
+			params.Key, err)
+
+		params.Stale = staleMeta.expired()
+
+		return nil
 	}
 
 	if err != nil {
 		return err
 	}
 
-	resultValue := reflect.ValueOf(params.Result).Elem()
-	resultValue.Set(reflect.ValueOf(newData))
+	setResultValue(reflect.ValueOf(params.Result).Elem(), newData)
 
 	return err
 }
 
+func (ru *RedisUtil) cWrapperFallback(ctx context.Context, params *WrapperParams) (interface{}, error) {
+	if !params.DistributedSingleFlight || params.FlushCache {
+		return ru.cWrapperCallAndSetCache(ctx, params)
+	}
+
+	return ru.distributedDo(ctx, params.Key, func() (interface{}, bool, error) {
+		return ru.tryGetValue(ctx, params.Key, reflect.TypeOf(params.Result).Elem(),
+			ru.getValueCodec(params.Codec))
+	}, func() (interface{}, error) {
+		return ru.cWrapperCallAndSetCache(ctx, params)
+	})
+}
+
 func (ru *RedisUtil) cWrapperCallAndSetCache(ctx context.Context,
 	params *WrapperParams) (interface{}, error) {
-	data, err := params.FallbackFunc()
+	start := time.Now()
+
+	data, err := ru.callFallback(ctx, params.FallbackTimeout, params.FallbackFunc)
+	if IsNotFound(err) {
+		ru.ruWrapperSetNotFound(ctx, params.Key, ru.jitterTTL(notFoundExpireSeconds(
+			params.NotFoundExpireSeconds, params.ExpireSeconds), params.TTLJitter), params.Codec, params.FlushCache)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	_ = ru.Set(ctx, params.Key, data, params.ExpireSeconds)
+	softExpireSeconds := 0
+	if params.staleWhileRevalidate() {
+		softExpireSeconds = params.SoftExpireSeconds
+	}
+
+	expireSeconds := ru.jitterTTL(params.ExpireSeconds, params.TTLJitter)
+	meta := newWrapperValueMeta(expireSeconds, softExpireSeconds, params.StaleIfErrorSeconds,
+		params.EarlyRecomputeBeta, time.Since(start))
+
+	_ = ru.tieredSet(ctx, params.Key, data, staleIfErrorTTL(expireSeconds, params.StaleIfErrorSeconds),
+		ru.getValueCodec(params.Codec), meta)
+
+	if params.FlushCache { // 通知其他进程删除一级缓存
+		ru.publishInvalidation(ctx, params.Key)
+	}
 
 	return data, nil
 }
 
+// 后台刷新缓存, 和前台调用共用 singleflight, 同一个 key 同时只有一个刷新任务
+func (ru *RedisUtil) cWrapperRefreshInBackground(ctx context.Context, params *WrapperParams) {
+	bgCtx := detachContext(ctx)
+	bgParams := *params
+
+	ru.singleflightGroup(params.Key).DoChan(params.Key, func() (interface{}, error) {
+		data, err := ru.cWrapperCallAndSetCache(bgCtx, &bgParams)
+		if err != nil && !IsNotFound(err) {
+			ru.getLogger().Errorf(bgCtx, "CacheUtil.cWrapperRefreshInBackground, key:%s, error:%+v", params.Key, err)
+		}
+
+		return data, err
+	})
+}
+
+// WrapperParamsMget 每次调用单独使用, 输出字段(NotFound 等)在调用开始时重置并写回 params, 不能在并发的调用间共享
 type WrapperParamsMget struct {
 	Keys          []string
 	ExpireSeconds []int
@@ -75,6 +191,28 @@ type WrapperParamsMget struct {
 
 	SingleFlight bool // 是否启动 singleflight
 	FlushCache   bool // 用SetFunc刷新缓存
+
+	Codec Codec // 为空时使用默认 codec
+
+	EarlyRecomputeBeta float64 // XFetch 概率提前重新计算, 同 WrapperParams
+
+	DistributedSingleFlight bool // 跨进程 singleflight, 同 WrapperParams
+
+	// 不存在标记的过期时间, 同 WrapperParams
+	// FallbackFuncSlice 返回 ErrNotFound, BatchFallbackFunc 返回 ErrNotFound 或结果中的值为 ErrNotFound 时表示不存在
+	NotFoundExpireSeconds int
+
+	TTLJitter *TTLJitter // 过期时间随机延长, 同 WrapperParams
+
+	// stale-if-error, 同 WrapperParams, 失败的 fallback 对应的 key 全部有旧值时才返回旧值, 否则返回错误
+	StaleIfErrorSeconds int
+
+	FallbackTimeout time.Duration // 单个 fallback 的超时时间, 同 WrapperParams
+	MaxConcurrency  int           // FallbackFuncSlice 的最大并发数, 为0 时不限制
+
+	Found    []bool // 输出, 和 Keys 一一对应, 命中缓存或 fallback 返回了值时为 true
+	NotFound []bool // 输出, 和 Keys 一一对应, 不存在的 key 为 true, 对应的结果为零值
+	Stale    []bool // 输出, 和 Keys 一一对应, 返回的是逻辑过期的旧值时为 true
 }
 
 // 多key 缓存获取wrapper mget
@@ -90,11 +228,39 @@ func (ru *RedisUtil) CacheWrapperMget(ctx context.Context,
 	}
 
 	hits := make([]bool, len(params.Keys))
+	params.Found = make([]bool, len(params.Keys))
+	params.NotFound = make([]bool, len(params.Keys))
+	params.Stale = make([]bool, len(params.Keys))
+	staleMetas := make([]*valueMeta, len(params.Keys)) // 需要重新计算但可以在 fallback 失败时返回的旧值
 
 	if !params.FlushCache { // 从缓存中获取 mget
-		if hits, err = ru.MGet(ctx, params.Keys, params.ResultSlice); err != nil {
+		var metas []*valueMeta
+
+		hits, metas, err = ru.tieredMGet(ctx, params.Keys, params.ResultSlice, ru.getValueCodec(params.Codec))
+		if err != nil {
 			return err
 		}
+
+		for i, meta := range metas {
+			if !hits[i] {
+				continue
+			}
+
+			if meta.notFound {
+				params.NotFound[i] = true
+				continue
+			}
+
+			// 逻辑过期和提前过期的当作未命中
+			if meta.expired() || meta.earlyExpired(params.EarlyRecomputeBeta, ru.rand.float64) {
+				hits[i] = false
+				staleMetas[i] = meta
+
+				continue
+			}
+
+			params.Found[i] = true
+		}
 	}
 
 	fallbackIndexes := make([]int, 0) // 缓存未命中的key
@@ -112,13 +278,13 @@ func (ru *RedisUtil) CacheWrapperMget(ctx context.Context,
 	resultRFElem := reflect.ValueOf(params.ResultSlice).Elem() // 结果
 
 	// 未命中缓存的处理 批量获取或并发获取
-	err = ru.ruWrapperMgetFallbackHandle(ctx, fallbackIndexes, resultRFElem, params)
+	err = ru.ruWrapperMgetFallbackHandle(ctx, fallbackIndexes, resultRFElem, params, staleMetas)
 
 	return err
 }
 
-func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context,
-	fallbackIndexes []int, resultRFElem reflect.Value, params *WrapperParamsMget) (err error) {
+func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context, fallbackIndexes []int,
+	resultRFElem reflect.Value, params *WrapperParamsMget, staleMetas []*valueMeta) (err error) {
 	if params.BatchFallbackFunc != nil { // 批量获取
 		var (
 			batchDataInter interface{}
@@ -126,13 +292,15 @@ func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context,
 		)
 
 		if params.SingleFlight {
-			singleflightKey := strings.Join(params.Keys, ",")
-			batchDataInter, err2, _ = ru.singleflightGroup(singleflightKey).
-				Do(singleflightKey, func() (interface{}, error) {
-					return ru.ruWrapperBatchCallAndSetCache(ctx, params, fallbackIndexes)
-				})
+			batchDataInter, err2 = ru.ruWrapperBatchSingleFlight(ctx, fallbackIndexes, resultRFElem, params)
 		} else {
-			batchDataInter, err2 = params.BatchFallbackFunc(fallbackIndexes)
+			batchDataInter, err2 = ru.ruWrapperBatchFallback(ctx, resultRFElem, params, fallbackIndexes)
+		}
+
+		if err2 != nil && !IsNotFound(err2) && params.StaleIfErrorSeconds > 0 {
+			if ru.ruWrapperMgetServeStale(ctx, fallbackIndexes, params, staleMetas, err2) {
+				return nil
+			}
 		}
 
 		if err2 != nil {
@@ -147,8 +315,13 @@ func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context,
 		// 结果
 		for _, fallbackIndex := range fallbackIndexes {
 			if newData, ok := batchData[fallbackIndex]; ok {
-				targetResultRFValue := resultRFElem.Index(fallbackIndex)
-				targetResultRFValue.Set(reflect.ValueOf(newData))
+				if isNotFoundValue(newData) {
+					params.NotFound[fallbackIndex] = true
+					continue
+				}
+
+				setResultValue(resultRFElem.Index(fallbackIndex), newData)
+				params.Found[fallbackIndex] = true
 			}
 		}
 
@@ -158,6 +331,10 @@ func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context,
 	// 并发获取
 	goGroup, _ := errgroup.WithContext(ctx)
 
+	if params.MaxConcurrency > 0 {
+		goGroup.SetLimit(params.MaxConcurrency)
+	}
+
 	for _, fallbackIndex := range fallbackIndexes {
 		fallbackIndex := fallbackIndex
 
@@ -170,17 +347,29 @@ func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context,
 
 			if params.SingleFlight {
 				newData, err2, _ = ru.singleflightGroup(key).Do(key, func() (interface{}, error) {
-					return ru.ruWrapperCallAndSetCache(ctx, params, fallbackIndex)
+					return ru.ruWrapperFallback(ctx, resultRFElem, params, fallbackIndex)
 				})
 			} else {
-				newData, err2 = ru.ruWrapperCallAndSetCache(ctx, params, fallbackIndex)
+				newData, err2 = ru.ruWrapperFallback(ctx, resultRFElem, params, fallbackIndex)
+			}
+
+			if IsNotFound(err2) {
+				params.NotFound[fallbackIndex] = true
+				return nil
+			}
+
+			if err2 != nil && params.StaleIfErrorSeconds > 0 {
+				if ru.ruWrapperMgetServeStale(ctx, []int{fallbackIndex}, params, staleMetas, err2) {
+					return nil
+				}
 			}
 
 			if err2 != nil {
 				return err2
 			}
 
-			targetResultRFValue.Set(reflect.ValueOf(newData)) // 结果
+			setResultValue(targetResultRFValue, newData) // 结果
+			params.Found[fallbackIndex] = true
 
 			return nil
 		})
@@ -189,17 +378,119 @@ func (ru *RedisUtil) ruWrapperMgetFallbackHandle(ctx context.Context,
 	return goGroup.Wait()
 }
 
+func (ru *RedisUtil) ruWrapperBatchFallback(ctx context.Context, resultRFElem reflect.Value,
+	params *WrapperParamsMget, fallbackIndexes []int) (interface{}, error) {
+	if !params.DistributedSingleFlight || params.FlushCache {
+		return ru.ruWrapperBatchCallAndSetCache(ctx, params, fallbackIndexes)
+	}
+
+	lockKeys := make([]string, len(fallbackIndexes))
+	for j, fallbackIndex := range fallbackIndexes {
+		lockKeys[j] = params.Keys[fallbackIndex]
+	}
+
+	return ru.distributedDo(ctx, batchLockKey(lockKeys), func() (interface{}, bool, error) {
+		return ru.tryMGetValues(ctx, params.Keys, fallbackIndexes,
+			resultRFElem.Type().Elem(), ru.getValueCodec(params.Codec))
+	}, func() (interface{}, error) {
+		return ru.ruWrapperBatchCallAndSetCache(ctx, params, fallbackIndexes)
+	})
+}
+
+// 按单个 key 去重的 singleflight, 其他调用方正在获取的 key 等待其结果, 只有剩余的 key 调用 BatchFallbackFunc
+func (ru *RedisUtil) ruWrapperBatchSingleFlight(ctx context.Context, fallbackIndexes []int,
+	resultRFElem reflect.Value, params *WrapperParamsMget) (map[int]interface{}, error) {
+	singleflightKeys := make([]string, len(fallbackIndexes)) // 带上前缀, 不同命名空间的 key 不共享
+	for j, fallbackIndex := range fallbackIndexes {
+		singleflightKeys[j] = ru.keyPatch(params.Keys[fallbackIndex])
+	}
+
+	// 不按 singleFlightGroupNum 分组: 同一个 key 必须始终落在同一组才能去重, 分组后一个批次要拆成多次 BatchFallbackFunc 调用
+	// BatchGroup 的锁只在登记和分发结果时持有, 不包含 fn 的执行时间, 单个全局组的竞争可以接受
+	data, err := singleflight.GetBatchGroup("CacheUtil:batch").Do(singleflightKeys,
+		func(indexes []int) (map[int]interface{}, error) {
+			callIndexes := make([]int, len(indexes))
+			for j, index := range indexes {
+				callIndexes[j] = fallbackIndexes[index]
+			}
+
+			batchDataInter, err := ru.ruWrapperBatchFallback(ctx, resultRFElem, params, callIndexes)
+			if err != nil {
+				return nil, err
+			}
+
+			batchData, _ := batchDataInter.(map[int]interface{})
+
+			result := make(map[int]interface{}, len(indexes))
+
+			for _, index := range indexes {
+				if value, ok := batchData[fallbackIndexes[index]]; ok {
+					result[index] = value
+				}
+			}
+
+			return result, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]interface{}, len(data))
+	for index, value := range data {
+		result[fallbackIndexes[index]] = value
+	}
+
+	return result, nil
+}
+
+// fallbackIndexes 全部有旧值时返回旧值, 旧值已经在 tieredMGet 中写入结果
+func (ru *RedisUtil) ruWrapperMgetServeStale(ctx context.Context, fallbackIndexes []int,
+	params *WrapperParamsMget, staleMetas []*valueMeta, fallbackErr error) bool {
+	for _, fallbackIndex := range fallbackIndexes {
+		if staleMetas[fallbackIndex] == nil {
+			return false
+		}
+	}
+
+	ru.getLogger().Errorf(ctx, "CacheUtil.CacheWrapperMget, keys:%v, fallback error, return stale value, error:%+v",
+		params.Keys, fallbackErr)
+
+	for _, fallbackIndex := range fallbackIndexes {
+		params.Found[fallbackIndex] = true
+		params.Stale[fallbackIndex] = staleMetas[fallbackIndex].expired()
+	}
+
+	return true
+}
+
 // 批量获取 fallback 函数调用和入缓存
 func (ru *RedisUtil) ruWrapperBatchCallAndSetCache(ctx context.Context,
 	params *WrapperParamsMget, fallbackIndexes []int) (map[int]interface{}, error) {
-	batchData, err := params.BatchFallbackFunc(fallbackIndexes)
+	start := time.Now()
+
+	batchDataInter, err := ru.callFallback(ctx, params.FallbackTimeout, func() (interface{}, error) {
+		return params.BatchFallbackFunc(fallbackIndexes)
+	})
+
+	batchData, _ := batchDataInter.(map[int]interface{})
+
+	if IsNotFound(err) { // 全部不存在
+		batchData, err = make(map[int]interface{}, len(fallbackIndexes)), nil
+		for _, fallbackIndex := range fallbackIndexes {
+			batchData[fallbackIndex] = ErrNotFound
+		}
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	computeCost := time.Since(start)
+
 	setKeys := make([]string, 0)
 	setValues := make([]interface{}, 0)
 	setExpireSecondsSlice := make([]int, 0)
+	setMetas := make([]*valueMeta, 0)
 
 	for _, fallbackIndex := range fallbackIndexes {
 		newData, ok := batchData[fallbackIndex]
@@ -208,19 +499,60 @@ func (ru *RedisUtil) ruWrapperBatchCallAndSetCache(ctx context.Context,
 		}
 
 		setKeys = append(setKeys, params.Keys[fallbackIndex])
+
+		if isNotFoundValue(newData) {
+			setValues = append(setValues, nil)
+			setExpireSecondsSlice = append(setExpireSecondsSlice, ru.jitterTTL(notFoundExpireSeconds(
+				params.NotFoundExpireSeconds, params.ExpireSeconds[fallbackIndex]), params.TTLJitter))
+			setMetas = append(setMetas, &valueMeta{notFound: true})
+
+			continue
+		}
+
+		expireSeconds := ru.jitterTTL(params.ExpireSeconds[fallbackIndex], params.TTLJitter)
+
 		setValues = append(setValues, newData)
-		setExpireSecondsSlice = append(setExpireSecondsSlice, params.ExpireSeconds[fallbackIndex])
+		setExpireSecondsSlice = append(setExpireSecondsSlice,
+			staleIfErrorTTL(expireSeconds, params.StaleIfErrorSeconds))
+		setMetas = append(setMetas, newWrapperValueMeta(expireSeconds, 0, params.StaleIfErrorSeconds,
+			params.EarlyRecomputeBeta, computeCost))
 	}
 
 	if len(setKeys) > 0 {
-		_ = ru.BatchSet(ctx, &BatchSetParams{
+		// 部分写入失败不影响返回结果, tieredBatchSet 中已记录日志
+		setResult, err2 := ru.tieredBatchSet(ctx, &BatchSetParams{
 			Keys: setKeys, Values: setValues, ExpireSecondsSlice: setExpireSecondsSlice,
+			Codec: params.Codec, metas: setMetas,
+			TTLJitter: &TTLJitter{}, // 上面已经随机延长过
 		})
+
+		if err2 != nil && setResult != nil && ru.batchSetFailedHook != nil {
+			ru.batchSetFailedHook(ctx, setResult)
+		}
+
+		if params.FlushCache { // 通知其他进程删除一级缓存
+			ru.publishInvalidation(ctx, setKeys...)
+		}
 	}
 
 	return batchData, nil
 }
 
+func (ru *RedisUtil) ruWrapperFallback(ctx context.Context, resultRFElem reflect.Value,
+	params *WrapperParamsMget, fallbackIndex int) (interface{}, error) {
+	if !params.DistributedSingleFlight || params.FlushCache {
+		return ru.ruWrapperCallAndSetCache(ctx, params, fallbackIndex)
+	}
+
+	key := params.Keys[fallbackIndex]
+
+	return ru.distributedDo(ctx, key, func() (interface{}, bool, error) {
+		return ru.tryGetValue(ctx, key, resultRFElem.Type().Elem(), ru.getValueCodec(params.Codec))
+	}, func() (interface{}, error) {
+		return ru.ruWrapperCallAndSetCache(ctx, params, fallbackIndex)
+	})
+}
+
 // 并发获取 fallback 函数调用和入缓存
 func (ru *RedisUtil) ruWrapperCallAndSetCache(ctx context.Context,
 	params *WrapperParamsMget, fallbackIndex int) (interface{}, error) {
@@ -228,16 +560,108 @@ func (ru *RedisUtil) ruWrapperCallAndSetCache(ctx context.Context,
 	expireSeconds := params.ExpireSeconds[fallbackIndex]
 	setFunc := params.FallbackFuncSlice[fallbackIndex]
 
-	data, err := setFunc(fallbackIndex)
+	start := time.Now()
+
+	data, err := ru.callFallback(ctx, params.FallbackTimeout, func() (interface{}, error) {
+		return setFunc(fallbackIndex)
+	})
+	if IsNotFound(err) {
+		ru.ruWrapperSetNotFound(ctx, key, ru.jitterTTL(notFoundExpireSeconds(
+			params.NotFoundExpireSeconds, expireSeconds), params.TTLJitter), params.Codec, params.FlushCache)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	_ = ru.Set(ctx, key, data, expireSeconds)
+	expireSeconds = ru.jitterTTL(expireSeconds, params.TTLJitter)
+	meta := newWrapperValueMeta(expireSeconds, 0, params.StaleIfErrorSeconds,
+		params.EarlyRecomputeBeta, time.Since(start))
+
+	_ = ru.tieredSet(ctx, key, data, staleIfErrorTTL(expireSeconds, params.StaleIfErrorSeconds),
+		ru.getValueCodec(params.Codec), meta)
+
+	if params.FlushCache { // 通知其他进程删除一级缓存
+		ru.publishInvalidation(ctx, key)
+	}
 
 	return data, nil
 }
 
+// 写入不存在的标记
+func (ru *RedisUtil) ruWrapperSetNotFound(ctx context.Context,
+	key string, expireSeconds int, codec Codec, flushCache bool) {
+	_ = ru.tieredSet(ctx, key, nil, expireSeconds, ru.getValueCodec(codec), &valueMeta{notFound: true})
+
+	if flushCache { // 通知其他进程删除一级缓存
+		ru.publishInvalidation(ctx, key)
+	}
+}
+
+// 不存在的标记的过期时间不超过正常值的过期时间
+func notFoundExpireSeconds(notFoundExpireSeconds, expireSeconds int) int {
+	if notFoundExpireSeconds <= 0 {
+		notFoundExpireSeconds = DefaultNotFoundExpireSeconds
+	}
+
+	if expireSeconds != TTLNoExpire && expireSeconds < notFoundExpireSeconds {
+		return expireSeconds
+	}
+
+	return notFoundExpireSeconds
+}
+
+// fallback 返回 nil 时设置为零值, 结果是接口类型时 reflect.ValueOf(nil) 无效, 直接 Set 会 panic
+func setResultValue(target reflect.Value, data interface{}) {
+	if data == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+
+	target.Set(reflect.ValueOf(data))
+}
+
+func isNotFoundValue(value interface{}) bool {
+	err, ok := value.(error)
+
+	return ok && IsNotFound(err)
+}
+
+// 开启 stale-if-error 时 redis 中多保留 staleIfErrorSeconds 秒
+func staleIfErrorTTL(expireSeconds, staleIfErrorSeconds int) int {
+	if staleIfErrorSeconds <= 0 || expireSeconds == TTLNoExpire {
+		return expireSeconds
+	}
+
+	return expireSeconds + staleIfErrorSeconds
+}
+
+// 缓存值的 meta, 没有开启相关功能时为nil
+func newWrapperValueMeta(expireSeconds, softExpireSeconds, staleIfErrorSeconds int,
+	earlyRecomputeBeta float64, computeCost time.Duration) *valueMeta {
+	var meta *valueMeta
+
+	now := nowMillis()
+
+	if softExpireSeconds > 0 {
+		meta = &valueMeta{softExpireAt: now + int64(softExpireSeconds)*1000}
+	}
+
+	if (earlyRecomputeBeta > 0 || staleIfErrorSeconds > 0) && expireSeconds != TTLNoExpire {
+		if meta == nil {
+			meta = &valueMeta{}
+		}
+
+		meta.expireAt = now + int64(expireSeconds)*1000
+	}
+
+	if earlyRecomputeBeta > 0 && expireSeconds != TTLNoExpire {
+		meta.computeCost = computeCost.Milliseconds() + 1 // 至少1毫秒
+	}
+
+	return meta
+}
+
 // 默认10组, 应该够用了，只是内存操作的lock
 func (ru *RedisUtil) singleflightGroup(key string) *singleflight.Group {
 	keyHash := base.CRC32(key)
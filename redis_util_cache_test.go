@@ -4,7 +4,11 @@ package redisutil
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -428,3 +432,496 @@ func TestCacheWrapperMgetBatchSingleFlight(t *testing.T) {
 		_ = redisUtil.Del(ctx, key)
 	}
 }
+
+func TestCacheWrapperMgetBatchSetFailedHook(t *testing.T) {
+	ctx := context.Background()
+
+	var failedKeys []string
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionBatchSetFailedHook(
+		func(ctx context.Context, result *BatchSetResult) {
+			failedKeys = result.FailedKeys()
+		}))
+
+	key1 := "gotest:redis_util:mget_hook1"
+	key2 := "gotest:redis_util:mget_hook2"
+
+	keys := []string{key1, key2}
+	expireSeconds := []int{600, 0} // 过期时间为0 写入失败
+
+	batchSetFunc := func(fallbackIndexes []int) (map[int]interface{}, error) {
+		result := make(map[int]interface{})
+
+		for _, i := range fallbackIndexes {
+			result[i] = fmt.Sprintf("value%d", i)
+		}
+
+		return result, nil
+	}
+
+	dataResult := make([]string, len(keys))
+
+	err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+		Keys:              keys,
+		ExpireSeconds:     expireSeconds,
+		ResultSlice:       &dataResult,
+		BatchFallbackFunc: batchSetFunc,
+		SingleFlight:      true,
+	})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"value0", "value1"}, dataResult)
+	assert.Equal(t, []string{key2}, failedKeys)
+
+	for _, key := range keys {
+		_ = redisUtil.Del(ctx, key)
+	}
+}
+
+type testErrorLogger struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (l *testErrorLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func (l *testErrorLogger) Infof(ctx context.Context, format string, args ...interface{}) {}
+
+// 没有设置 OptionBatchSetFailedHook 时, 回写缓存部分失败也要记录日志
+func TestCacheWrapperMgetBatchSetFailedLog(t *testing.T) {
+	ctx := context.Background()
+
+	logger := &testErrorLogger{}
+	redisUtil := NewRedisUtil(getTestPool(), OptionLogger(logger))
+
+	keys := []string{"gotest:redis_util:mget_log1", "gotest:redis_util:mget_log2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	dataResult := make([]string, len(keys))
+
+	err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+		Keys:          keys,
+		ExpireSeconds: []int{600, 0}, // 过期时间为0 写入失败
+		ResultSlice:   &dataResult,
+		BatchFallbackFunc: func(fallbackIndexes []int) (map[int]interface{}, error) {
+			return map[int]interface{}{0: "value0", 1: "value1"}, nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"value0", "value1"}, dataResult)
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	assert.Equal(t, 1, len(logger.errors))
+	assert.True(t, strings.Contains(logger.errors[0], "CacheUtil.BatchSet"))
+}
+
+func TestCacheWrapperStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_wrapper:stale_while_revalidate"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	var fallbackCount int32
+
+	fallbackFunc := func() (interface{}, error) {
+		n := atomic.AddInt32(&fallbackCount, 1)
+		time.Sleep(time.Millisecond * 200)
+
+		return fmt.Sprintf("value%d", n), nil
+	}
+
+	getFunc := func() string {
+		result := ""
+
+		err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: key, ExpireSeconds: 600, SoftExpireSeconds: 1,
+			Result: &result, FallbackFunc: fallbackFunc,
+		})
+		assert.Equal(t, nil, err)
+
+		return result
+	}
+
+	assert.Equal(t, "value1", getFunc())
+
+	time.Sleep(time.Millisecond * 1100)
+
+	// 超过 soft ttl 直接返回旧值, 只有一个后台刷新
+	goGroup, _ := errgroup.WithContext(ctx)
+
+	for j := 0; j < 10; j++ {
+		goGroup.Go(func() error {
+			start := time.Now()
+
+			assert.Equal(t, "value1", getFunc())
+			assert.True(t, time.Since(start) < time.Millisecond*100)
+
+			return nil
+		})
+	}
+
+	_ = goGroup.Wait()
+
+	time.Sleep(time.Millisecond * 300)
+
+	assert.Equal(t, "value2", getFunc())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fallbackCount))
+}
+
+func TestCacheWrapperEarlyRecompute(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_wrapper:early_recompute"
+	keys := []string{"gotest:redis_wrapper:early_recompute_mget1", "gotest:redis_wrapper:early_recompute_mget2"}
+
+	defer func() {
+		for _, k := range append(keys, key) {
+			_ = redisUtil.Del(ctx, k)
+		}
+	}()
+
+	fallbackCount := 0
+	fallbackFunc := func() (interface{}, error) {
+		fallbackCount++
+		time.Sleep(time.Millisecond * 10)
+
+		return fmt.Sprintf("value%d", fallbackCount), nil
+	}
+
+	getFunc := func(beta float64) string {
+		result := ""
+
+		err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: key, ExpireSeconds: 600, EarlyRecomputeBeta: beta,
+			Result: &result, FallbackFunc: fallbackFunc,
+		})
+		assert.Equal(t, nil, err)
+
+		return result
+	}
+
+	// beta 很小时离过期还很远, 不会提前计算
+	assert.Equal(t, "value1", getFunc(1))
+	assert.Equal(t, "value1", getFunc(1))
+
+	// beta 足够大时必然提前计算
+	assert.Equal(t, "value2", getFunc(1e12))
+	assert.Equal(t, 2, fallbackCount)
+
+	// 没有开启时不会提前计算
+	assert.Equal(t, "value2", getFunc(0))
+
+	batchFallbackCount := 0
+	batchFallbackFunc := func(fallbackIndexes []int) (map[int]interface{}, error) {
+		batchFallbackCount++
+		time.Sleep(time.Millisecond * 10)
+
+		result := make(map[int]interface{})
+		for _, i := range fallbackIndexes {
+			result[i] = fmt.Sprintf("value%d", batchFallbackCount)
+		}
+
+		return result, nil
+	}
+
+	mgetFunc := func(beta float64) []string {
+		dataResult := make([]string, len(keys))
+
+		err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+			Keys: keys, ExpireSeconds: []int{600, 600}, EarlyRecomputeBeta: beta,
+			ResultSlice: &dataResult, BatchFallbackFunc: batchFallbackFunc,
+		})
+		assert.Equal(t, nil, err)
+
+		return dataResult
+	}
+
+	assert.Equal(t, []string{"value1", "value1"}, mgetFunc(1))
+	assert.Equal(t, []string{"value1", "value1"}, mgetFunc(1))
+	assert.Equal(t, []string{"value2", "value2"}, mgetFunc(1e12))
+	assert.Equal(t, 2, batchFallbackCount)
+}
+
+func TestCacheWrapperNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_wrapper:not_found"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	fallbackCount := 0
+	fallbackFunc := func() (interface{}, error) {
+		fallbackCount++
+
+		return nil, ErrNotFound
+	}
+
+	for i := 0; i < 2; i++ {
+		result := ""
+
+		err := redisUtil.CacheWrapper(ctx, &WrapperParams{
+			Key: key, ExpireSeconds: 600, NotFoundExpireSeconds: 1,
+			Result: &result, FallbackFunc: fallbackFunc,
+		})
+		assert.True(t, IsNotFound(err))
+		assert.Equal(t, "", result)
+	}
+
+	assert.Equal(t, 1, fallbackCount)
+
+	// 普通 Get 当作不存在
+	value := ""
+	hit, err := redisUtil.Get(ctx, key, &value)
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+
+	// 不存在的标记过期后重新调用 FallbackFunc
+	time.Sleep(time.Millisecond * 1100)
+
+	result := ""
+	err = redisUtil.CacheWrapper(ctx, &WrapperParams{
+		Key: key, ExpireSeconds: 600, Result: &result,
+		FallbackFunc: func() (interface{}, error) {
+			return "value", nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+}
+
+func TestCacheWrapperMgetNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	keys := []string{"gotest:redis_util:not_found_mget1", "gotest:redis_util:not_found_mget2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	batchFallbackCount := 0
+	batchFallbackFunc := func(fallbackIndexes []int) (map[int]interface{}, error) {
+		batchFallbackCount++
+
+		return map[int]interface{}{0: "value0", 1: ErrNotFound}, nil
+	}
+
+	var fallbackCount int32 // FallbackFuncSlice 并发调用
+
+	fallbackFuncSlice := []MgetFallbackFunc{
+		func(fallbackIndex int) (interface{}, error) {
+			atomic.AddInt32(&fallbackCount, 1)
+			return "value0", nil
+		},
+		func(fallbackIndex int) (interface{}, error) {
+			atomic.AddInt32(&fallbackCount, 1)
+			return nil, ErrNotFound
+		},
+	}
+
+	for _, params := range []*WrapperParamsMget{
+		{BatchFallbackFunc: batchFallbackFunc},
+		{FallbackFuncSlice: fallbackFuncSlice},
+	} {
+		for i := 0; i < 2; i++ {
+			dataResult := make([]string, len(keys))
+
+			params.Keys = keys
+			params.ExpireSeconds = []int{600, 600}
+			params.ResultSlice = &dataResult
+
+			err := redisUtil.CacheWrapperMget(ctx, params)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, []string{"value0", ""}, dataResult)
+			assert.Equal(t, []bool{false, true}, params.NotFound)
+		}
+
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}
+
+	assert.Equal(t, 1, batchFallbackCount)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fallbackCount))
+}
+
+func TestCacheWrapperStaleIfError(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_wrapper:stale_if_error"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	fallbackErr := errors.New("fallback error")
+
+	params := &WrapperParams{
+		Key: key, ExpireSeconds: 1, StaleIfErrorSeconds: 600,
+		FallbackFunc: func() (interface{}, error) {
+			return "value", nil
+		},
+	}
+
+	result := ""
+	params.Result = &result
+	err := redisUtil.CacheWrapper(ctx, params)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+	assert.False(t, params.Stale)
+
+	time.Sleep(time.Millisecond * 1100)
+
+	// 逻辑过期后 Get 不再命中
+	value := ""
+	hit, _ := redisUtil.Get(ctx, key, &value)
+	assert.False(t, hit)
+
+	// FallbackFunc 失败时返回旧值
+	params.FallbackFunc = func() (interface{}, error) {
+		return nil, fallbackErr
+	}
+
+	result = ""
+	err = redisUtil.CacheWrapper(ctx, params)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "value", result)
+	assert.True(t, params.Stale)
+
+	// 没有开启时返回错误
+	params.StaleIfErrorSeconds = 0
+	err = redisUtil.CacheWrapper(ctx, params)
+	assert.Equal(t, fallbackErr, err)
+
+	keys := []string{"gotest:redis_util:stale_if_error_mget1", "gotest:redis_util:stale_if_error_mget2"}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	fallbackFuncSlice := []MgetFallbackFunc{
+		func(fallbackIndex int) (interface{}, error) {
+			return "value0", nil
+		},
+		func(fallbackIndex int) (interface{}, error) {
+			return "value1", nil
+		},
+	}
+
+	mgetParams := &WrapperParamsMget{
+		Keys: keys, ExpireSeconds: []int{1, 600}, StaleIfErrorSeconds: 600,
+		FallbackFuncSlice: fallbackFuncSlice,
+	}
+
+	dataResult := make([]string, len(keys))
+	mgetParams.ResultSlice = &dataResult
+	err = redisUtil.CacheWrapperMget(ctx, mgetParams)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"value0", "value1"}, dataResult)
+
+	time.Sleep(time.Millisecond * 1100)
+
+	fallbackFuncSlice[0] = func(fallbackIndex int) (interface{}, error) {
+		return nil, fallbackErr
+	}
+
+	dataResult = make([]string, len(keys))
+	err = redisUtil.CacheWrapperMget(ctx, mgetParams)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"value0", "value1"}, dataResult)
+	assert.Equal(t, []bool{true, false}, mgetParams.Stale)
+}
+
+func TestCacheWrapperMgetBatchSingleFlightOverlap(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	keyPrefix := "gotest:redis_util:batch_overlap_"
+
+	defer func() {
+		for _, name := range []string{"a", "b", "c", "d"} {
+			_ = redisUtil.Del(ctx, keyPrefix+name)
+		}
+	}()
+
+	var mu sync.Mutex
+
+	loadedKeys := make([]string, 0)
+	started := make(chan struct{})
+
+	mgetFunc := func(names []string) []string {
+		keys := make([]string, len(names))
+		for i, name := range names {
+			keys[i] = keyPrefix + name
+		}
+
+		dataResult := make([]string, len(keys))
+
+		err := redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+			Keys: keys, ExpireSeconds: []int{600, 600, 600}, ResultSlice: &dataResult,
+			BatchFallbackFunc: func(fallbackIndexes []int) (map[int]interface{}, error) {
+				result := make(map[int]interface{})
+
+				mu.Lock()
+				for _, i := range fallbackIndexes {
+					loadedKeys = append(loadedKeys, names[i])
+					result[i] = "value_" + names[i]
+				}
+				mu.Unlock()
+
+				if names[0] == "a" {
+					close(started)
+					time.Sleep(time.Millisecond * 200)
+				}
+
+				return result, nil
+			},
+			SingleFlight: true,
+		})
+		assert.Equal(t, nil, err)
+
+		return dataResult
+	}
+
+	goGroup, _ := errgroup.WithContext(ctx)
+
+	goGroup.Go(func() error {
+		assert.Equal(t, []string{"value_a", "value_b", "value_c"}, mgetFunc([]string{"a", "b", "c"}))
+		return nil
+	})
+
+	<-started
+
+	// b, c 正在获取中, 只获取 d
+	assert.Equal(t, []string{"value_b", "value_c", "value_d"}, mgetFunc([]string{"b", "c", "d"}))
+
+	_ = goGroup.Wait()
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, loadedKeys)
+}
@@ -0,0 +1,305 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// hash 的值和 Set 一样通过 codec 编码, 整数直接存储, 可以使用 HIncrBy
+
+func (ru *RedisUtil) HSet(ctx context.Context, key string, field string, value interface{}) (err error) {
+	bytesData, err := ru.getValueCodec(nil).encode(value)
+	if err != nil {
+		return err
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		_, err = conDo(ctx, con, "HSET", ru.keyPatch(key), field, bytesData)
+
+		return errors.WithStack(err)
+	})
+
+	return err
+}
+
+func (ru *RedisUtil) HGet(ctx context.Context, key string, field string, value interface{}) (hit bool, err error) {
+	return ru.getElement(ctx, ru.getValueCodec(nil), value, "HGET", ru.keyPatch(key), field)
+}
+
+// HMGet valuesInter 必须是和 fields 等长的 slice 的指针
+func (ru *RedisUtil) HMGet(ctx context.Context,
+	key string, fields []string, valuesInter interface{}) (hits []bool, err error) {
+	valuesInterRFElem, err := sliceResultValue(fields, valuesInter)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, 0, len(fields)+1)
+	args = append(args, ru.keyPatch(key))
+
+	for _, field := range fields {
+		args = append(args, field)
+	}
+
+	var redisResult [][]byte
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		redisResult, err = redis.ByteSlices(conDo(ctx, con, "HMGET", args...))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	vc := ru.getValueCodec(nil)
+	hits = make([]bool, len(fields))
+
+	for i, data := range redisResult {
+		if data == nil {
+			continue
+		}
+
+		if hits[i], err = vc.decode(data, valuesInterRFElem.Index(i).Addr().Interface()); err != nil {
+			return nil, err
+		}
+	}
+
+	return hits, nil
+}
+
+// HGetAll valuesInter 必须是 map[string]T 的指针, 结果写入该 map
+func (ru *RedisUtil) HGetAll(ctx context.Context, key string, valuesInter interface{}) (err error) {
+	valuesRF := reflect.ValueOf(valuesInter)
+	if valuesRF.Kind() != reflect.Ptr || valuesRF.Elem().Kind() != reflect.Map ||
+		valuesRF.Elem().Type().Key().Kind() != reflect.String {
+		return errors.New(fmt.Sprintf("valuesInter is not ptr of map[string]T: %T", valuesInter))
+	}
+
+	redisResult, err := ru.hgetAllBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	mapRF := valuesRF.Elem()
+	if mapRF.IsNil() {
+		mapRF.Set(reflect.MakeMapWithSize(mapRF.Type(), len(redisResult)))
+	}
+
+	vc := ru.getValueCodec(nil)
+
+	for field, data := range redisResult {
+		valuePtr := reflect.New(mapRF.Type().Elem())
+
+		hit, err := vc.decode(data, valuePtr.Interface())
+		if err != nil {
+			return err
+		}
+
+		if hit {
+			mapRF.SetMapIndex(reflect.ValueOf(field).Convert(mapRF.Type().Key()), valuePtr.Elem())
+		}
+	}
+
+	return nil
+}
+
+func (ru *RedisUtil) hgetAllBytes(ctx context.Context, key string) (result map[string][]byte, err error) {
+	var values [][]byte
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		values, err = redis.ByteSlices(conDo(ctx, con, "HGETALL", ru.keyPatch(key)))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result = make(map[string][]byte, len(values)/2)
+
+	for i := 0; i+1 < len(values); i += 2 {
+		result[string(values[i])] = values[i+1]
+	}
+
+	return result, nil
+}
+
+func (ru *RedisUtil) HDel(ctx context.Context, key string, fields ...string) (res int64, err error) {
+	args := make([]interface{}, 0, len(fields)+1)
+	args = append(args, ru.keyPatch(key))
+
+	for _, field := range fields {
+		args = append(args, field)
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "HDEL", args...))
+
+		return err
+	})
+
+	return res, err
+}
+
+func (ru *RedisUtil) HIncrBy(ctx context.Context, key string, field string, diff int64) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "HINCRBY", ru.keyPatch(key), field, diff))
+
+		return err
+	})
+
+	return res, err
+}
+
+func (ru *RedisUtil) HExists(ctx context.Context, key string, field string) (res bool, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Bool(conDo(ctx, con, "HEXISTS", ru.keyPatch(key), field))
+
+		return err
+	})
+
+	return res, err
+}
+
+func (ru *RedisUtil) HLen(ctx context.Context, key string) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "HLEN", ru.keyPatch(key)))
+
+		return err
+	})
+
+	return res, err
+}
+
+// HSetStruct 把结构体的导出字段写入 hash, field 名默认为字段名, 可以通过 `redis:"name"` 指定, `redis:"-"` 忽略
+// 值为 nil 的指针字段不写入, ttl 为 TTLNoExpire 时不设置过期时间
+func (ru *RedisUtil) HSetStruct(ctx context.Context, key string, value interface{}, ttl int) (err error) {
+	valueRF := reflect.Indirect(reflect.ValueOf(value))
+	if valueRF.Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("value is not struct or ptr of struct: %T", value))
+	}
+
+	vc := ru.getValueCodec(nil)
+	args := []interface{}{ru.keyPatch(key)}
+
+	for _, field := range getHashStructFields(valueRF.Type()) {
+		fieldRF := valueRF.FieldByIndex(field.index)
+		if fieldRF.Kind() == reflect.Ptr && fieldRF.IsNil() {
+			continue
+		}
+
+		bytesData, err2 := vc.encode(fieldRF.Interface())
+		if err2 != nil {
+			return errors.WithMessage(err2, field.name)
+		}
+
+		args = append(args, field.name, bytesData)
+	}
+
+	if len(args) < 2 {
+		return nil
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		if err2 := conSend(ctx, con, "MULTI"); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conSend(ctx, con, "HSET", args...); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if ttl != TTLNoExpire {
+			if err2 := conSend(ctx, con, "EXPIRE", ru.keyPatch(key), ttl); err2 != nil {
+				return errors.WithStack(err2)
+			}
+		}
+
+		_, err2 := conDo(ctx, con, "EXEC")
+
+		return errors.WithStack(err2)
+	})
+
+	return err
+}
+
+// HGetStruct 读取 hash 写入结构体, value 必须是结构体指针, hash 中没有的字段保持不变, key 不存在时 hit 为 false
+func (ru *RedisUtil) HGetStruct(ctx context.Context, key string, value interface{}) (hit bool, err error) {
+	valueRF := reflect.ValueOf(value)
+	if valueRF.Kind() != reflect.Ptr || valueRF.Elem().Kind() != reflect.Struct {
+		return false, errors.New(fmt.Sprintf("value is not ptr of struct: %T", value))
+	}
+
+	redisResult, err := ru.hgetAllBytes(ctx, key)
+	if err != nil || len(redisResult) < 1 {
+		return false, err
+	}
+
+	vc := ru.getValueCodec(nil)
+	valueRF = valueRF.Elem()
+
+	for _, field := range getHashStructFields(valueRF.Type()) {
+		data, ok := redisResult[field.name]
+		if !ok {
+			continue
+		}
+
+		fieldRF := valueRF.FieldByIndex(field.index)
+
+		if _, err = vc.decode(data, fieldRF.Addr().Interface()); err != nil {
+			return false, errors.WithMessage(err, field.name)
+		}
+	}
+
+	return true, nil
+}
+
+type hashStructField struct {
+	name  string
+	index []int
+}
+
+var hashStructFieldsCache sync.Map // reflect.Type => []*hashStructField
+
+func getHashStructFields(t reflect.Type) []*hashStructField {
+	if value, ok := hashStructFieldsCache.Load(t); ok {
+		return value.([]*hashStructField)
+	}
+
+	fields := make([]*hashStructField, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if structField.PkgPath != "" { // 未导出
+			continue
+		}
+
+		name := structField.Name
+
+		if tag, ok := structField.Tag.Lookup("redis"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, &hashStructField{name: name, index: structField.Index})
+	}
+
+	hashStructFieldsCache.Store(t, fields)
+
+	return fields
+}
@@ -0,0 +1,108 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:hash"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	err := redisUtil.HSet(ctx, key, "name", "TestName")
+	assert.Equal(t, nil, err)
+
+	err = redisUtil.HSet(ctx, key, "age", 18)
+	assert.Equal(t, nil, err)
+
+	name := ""
+	hit, err := redisUtil.HGet(ctx, key, "name", &name)
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, "TestName", name)
+
+	hit, err = redisUtil.HGet(ctx, key, "not_exist", &name)
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+
+	age, err := redisUtil.HIncrBy(ctx, key, "age", 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(20), age)
+
+	ages := make([]int, 2)
+	hits, err := redisUtil.HMGet(ctx, key, []string{"age", "not_exist"}, &ages)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false}, hits)
+	assert.Equal(t, 20, ages[0])
+
+	exists, _ := redisUtil.HExists(ctx, key, "age")
+	assert.True(t, exists)
+
+	length, _ := redisUtil.HLen(ctx, key)
+	assert.Equal(t, int64(2), length)
+
+	all := make(map[string]int)
+	_, _ = redisUtil.HDel(ctx, key, "name")
+	err = redisUtil.HGetAll(ctx, key, &all)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, map[string]int{"age": 20}, all)
+}
+
+func TestHashStruct(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:hash_struct"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	type tags struct {
+		Values []string
+	}
+
+	type user struct {
+		Name     string `redis:"name"`
+		Age      int    `redis:"age"`
+		Nickname *string
+		Tags     *tags
+		Password string `redis:"-"`
+		internal int
+	}
+
+	nickname := "nick"
+	value := &user{Name: "TestName", Age: 18, Nickname: &nickname, Password: "secret", internal: 1}
+
+	err := redisUtil.HSetStruct(ctx, key, value, 600)
+	assert.Equal(t, nil, err)
+
+	length, _ := redisUtil.HLen(ctx, key)
+	assert.Equal(t, int64(3), length) // name, age, Nickname
+
+	ttl, _ := redisUtil.TTL(ctx, key)
+	assert.True(t, ttl > 0)
+
+	// 只更新单个字段
+	_, err = redisUtil.HIncrBy(ctx, key, "age", 1)
+	assert.Equal(t, nil, err)
+
+	result := &user{}
+	hit, err := redisUtil.HGetStruct(ctx, key, result)
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, &user{Name: "TestName", Age: 19, Nickname: &nickname}, result)
+
+	hit, err = redisUtil.HGetStruct(ctx, key+"_not_exist", result)
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+}
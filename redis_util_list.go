@@ -0,0 +1,184 @@
+package redisutil
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// list 的元素通过 codec 编码, 不使用信封和压缩(见 getMemberCodec), LRem 按编码后的值比较
+
+func (ru *RedisUtil) LPush(ctx context.Context, key string, values ...interface{}) (length int64, err error) {
+	return ru.push(ctx, "LPUSH", key, values)
+}
+
+func (ru *RedisUtil) RPush(ctx context.Context, key string, values ...interface{}) (length int64, err error) {
+	return ru.push(ctx, "RPUSH", key, values)
+}
+
+func (ru *RedisUtil) push(ctx context.Context,
+	command string, key string, values []interface{}) (length int64, err error) {
+	args, err := ru.encodeArgs(ru.keyPatch(key), values)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		length, err = redis.Int64(conDo(ctx, con, command, args...))
+
+		return errors.WithStack(err)
+	})
+
+	return length, err
+}
+
+// 按成员编码后追加到 prefix 之后, 作为命令参数
+func (ru *RedisUtil) encodeArgs(prefix interface{}, values []interface{}) ([]interface{}, error) {
+	vc := ru.getMemberCodec()
+
+	args := make([]interface{}, 0, len(values)+1)
+	args = append(args, prefix)
+
+	for _, value := range values {
+		bytesData, err := vc.encode(value)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, bytesData)
+	}
+
+	return args, nil
+}
+
+// LPop 列表为空时 hit 为 false
+func (ru *RedisUtil) LPop(ctx context.Context, key string, value interface{}) (hit bool, err error) {
+	return ru.getElement(ctx, ru.getMemberCodec(), value, "LPOP", ru.keyPatch(key))
+}
+
+func (ru *RedisUtil) RPop(ctx context.Context, key string, value interface{}) (hit bool, err error) {
+	return ru.getElement(ctx, ru.getMemberCodec(), value, "RPOP", ru.keyPatch(key))
+}
+
+// LIndex 下标超出范围时 hit 为 false
+func (ru *RedisUtil) LIndex(ctx context.Context, key string, index int, value interface{}) (hit bool, err error) {
+	return ru.getElement(ctx, ru.getMemberCodec(), value, "LINDEX", ru.keyPatch(key), index)
+}
+
+// 执行返回单个值的命令并通过 vc 解码, 返回 nil 时 hit 为 false
+func (ru *RedisUtil) getElement(ctx context.Context,
+	vc *valueCodec, value interface{}, command string, args ...interface{}) (hit bool, err error) {
+	if reflect.ValueOf(value).Kind() != reflect.Ptr {
+		return false, errors.New("value must be ptr")
+	}
+
+	var replay []byte
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		replay, err = redis.Bytes(conDo(ctx, con, command, args...))
+
+		return err
+	})
+
+	if err == redis.ErrNil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return vc.decode(replay, value)
+}
+
+// LRange valuesInter 必须是 slice 的指针, 例如 *[]string, 结果覆盖原来的 slice
+func (ru *RedisUtil) LRange(ctx context.Context, key string, start, stop int, valuesInter interface{}) (err error) {
+	return ru.getSlice(ctx, valuesInter, "LRANGE", ru.keyPatch(key), start, stop)
+}
+
+func (ru *RedisUtil) LLen(ctx context.Context, key string) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "LLEN", ru.keyPatch(key)))
+
+		return err
+	})
+
+	return res, err
+}
+
+func (ru *RedisUtil) LTrim(ctx context.Context, key string, start, stop int) (err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		_, err = conDo(ctx, con, "LTRIM", ru.keyPatch(key), start, stop)
+
+		return err
+	})
+
+	return err
+}
+
+// LRem 删除 count 个等于 value 的元素, 返回删除的数量, count 的含义同 redis LREM
+func (ru *RedisUtil) LRem(ctx context.Context, key string, count int, value interface{}) (res int64, err error) {
+	bytesData, err := ru.getMemberCodec().encode(value)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "LREM", ru.keyPatch(key), count, bytesData))
+
+		return err
+	})
+
+	return res, err
+}
+
+// CappedListPush 在一个事务中 LPUSH, LTRIM 只保留最新的 maxLen 个元素, 并设置过期时间, 用于 "最近 N 条" 的场景
+// ttl 为 TTLNoExpire 时不设置过期时间, 返回 LPUSH 之后(LTRIM 之前)的长度
+func (ru *RedisUtil) CappedListPush(ctx context.Context,
+	key string, maxLen int, ttl int, values ...interface{}) (length int64, err error) {
+	if maxLen < 1 {
+		return 0, errors.New("maxLen must be greater than 0")
+	}
+
+	args, err := ru.encodeArgs(ru.keyPatch(key), values)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		if err2 := conSend(ctx, con, "MULTI"); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conSend(ctx, con, "LPUSH", args...); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if err2 := conSend(ctx, con, "LTRIM", ru.keyPatch(key), 0, maxLen-1); err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if ttl != TTLNoExpire {
+			if err2 := conSend(ctx, con, "EXPIRE", ru.keyPatch(key), ttl); err2 != nil {
+				return errors.WithStack(err2)
+			}
+		}
+
+		replies, err2 := redis.Values(conDo(ctx, con, "EXEC"))
+		if err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		if len(replies) < 1 {
+			return errors.New("CappedListPush, empty EXEC reply")
+		}
+
+		length, err2 = redis.Int64(replies[0], nil)
+
+		return errors.WithStack(err2)
+	})
+
+	return length, err
+}
@@ -0,0 +1,128 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:list"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	length, err := redisUtil.RPush(ctx, key, "b", "c")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), length)
+
+	length, err = redisUtil.LPush(ctx, key, "a")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(3), length)
+
+	values := make([]string, 0)
+	err = redisUtil.LRange(ctx, key, 0, -1, &values)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+
+	value := ""
+	hit, err := redisUtil.LIndex(ctx, key, 1, &value)
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, "b", value)
+
+	hit, _ = redisUtil.LIndex(ctx, key, 10, &value)
+	assert.False(t, hit)
+
+	n, err := redisUtil.LRem(ctx, key, 0, "b")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	hit, _ = redisUtil.LPop(ctx, key, &value)
+	assert.True(t, hit)
+	assert.Equal(t, "a", value)
+
+	hit, _ = redisUtil.RPop(ctx, key, &value)
+	assert.True(t, hit)
+	assert.Equal(t, "c", value)
+
+	hit, err = redisUtil.RPop(ctx, key, &value)
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+
+	_, _ = redisUtil.RPush(ctx, key, 1, 2, 3)
+	err = redisUtil.LTrim(ctx, key, 0, 1)
+	assert.Equal(t, nil, err)
+
+	length, _ = redisUtil.LLen(ctx, key)
+	assert.Equal(t, int64(2), length)
+}
+
+// 成员不使用信封和压缩, schema version 变化或者值超过压缩阈值时 LRem 仍然可以匹配
+func TestListMemberCodec(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtilV1 := NewRedisUtil(getTestPool(), OptionEnvelope(1), OptionCompress(&GzipCompressor{}, 1))
+	redisUtilV2 := NewRedisUtil(getTestPool(), OptionEnvelope(2), OptionCompress(&GzipCompressor{}, 1))
+	key := "gotest:redis_util:list_member"
+
+	defer func() {
+		_ = redisUtilV1.Del(ctx, key)
+	}()
+
+	value := strings.Repeat("member", 100)
+
+	_, err := redisUtilV1.RPush(ctx, key, value, "other")
+	assert.Equal(t, nil, err)
+
+	values := make([]string, 0)
+	err = redisUtilV2.LRange(ctx, key, 0, -1, &values)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{value, "other"}, values)
+
+	n, err := redisUtilV2.LRem(ctx, key, 0, value)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	length, _ := redisUtilV2.LLen(ctx, key)
+	assert.Equal(t, int64(1), length)
+}
+
+func TestCappedListPush(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:capped_list"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	type event struct {
+		ID   int
+		Name string
+	}
+
+	for i := 1; i <= 5; i++ {
+		_, err := redisUtil.CappedListPush(ctx, key, 3, 600, &event{ID: i, Name: "event"})
+		assert.Equal(t, nil, err)
+	}
+
+	// 只保留最新的3个
+	events := make([]*event, 0)
+	err := redisUtil.LRange(ctx, key, 0, -1, &events)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, 5, events[0].ID)
+	assert.Equal(t, 3, events[2].ID)
+
+	ttl, _ := redisUtil.TTL(ctx, key)
+	assert.True(t, ttl > 0)
+}
@@ -0,0 +1,170 @@
+package redisutil
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// set 的成员通过 codec 编码, 不使用信封和压缩(见 getMemberCodec), SIsMember, SRem 等按编码后的值比较
+// 同一个 set 要使用相同类型的成员
+
+// SAdd 返回新增的成员数量
+func (ru *RedisUtil) SAdd(ctx context.Context, key string, members ...interface{}) (res int64, err error) {
+	return ru.setMembersDo(ctx, "SADD", key, members)
+}
+
+// SRem 返回删除的成员数量
+func (ru *RedisUtil) SRem(ctx context.Context, key string, members ...interface{}) (res int64, err error) {
+	return ru.setMembersDo(ctx, "SREM", key, members)
+}
+
+func (ru *RedisUtil) setMembersDo(ctx context.Context,
+	command string, key string, members []interface{}) (res int64, err error) {
+	args, err := ru.encodeArgs(ru.keyPatch(key), members)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, command, args...))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// SMembers valuesInter 必须是 slice 的指针, 例如 *[]string, 结果覆盖原来的 slice
+func (ru *RedisUtil) SMembers(ctx context.Context, key string, valuesInter interface{}) (err error) {
+	return ru.getSlice(ctx, valuesInter, "SMEMBERS", ru.keyPatch(key))
+}
+
+func (ru *RedisUtil) SIsMember(ctx context.Context, key string, member interface{}) (res bool, err error) {
+	bytesData, err := ru.getMemberCodec().encode(member)
+	if err != nil {
+		return false, err
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Bool(conDo(ctx, con, "SISMEMBER", ru.keyPatch(key), bytesData))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// SMIsMember 结果和 members 一一对应, 需要 redis 6.2 及以上
+func (ru *RedisUtil) SMIsMember(ctx context.Context, key string, members ...interface{}) (res []bool, err error) {
+	if len(members) < 1 {
+		return []bool{}, nil
+	}
+
+	args, err := ru.encodeArgs(ru.keyPatch(key), members)
+	if err != nil {
+		return nil, err
+	}
+
+	var ints []int
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		ints, err = redis.Ints(conDo(ctx, con, "SMISMEMBER", args...))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	res = make([]bool, len(ints))
+	for i, v := range ints {
+		res[i] = v == 1
+	}
+
+	return res, nil
+}
+
+func (ru *RedisUtil) SCard(ctx context.Context, key string) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "SCARD", ru.keyPatch(key)))
+
+		return err
+	})
+
+	return res, err
+}
+
+// SInter valuesInter 必须是 slice 的指针, 例如 *[]string
+func (ru *RedisUtil) SInter(ctx context.Context, keys []string, valuesInter interface{}) (err error) {
+	return ru.getSlice(ctx, valuesInter, "SINTER", ru.keysPatch(keys)...)
+}
+
+func (ru *RedisUtil) SUnion(ctx context.Context, keys []string, valuesInter interface{}) (err error) {
+	return ru.getSlice(ctx, valuesInter, "SUNION", ru.keysPatch(keys)...)
+}
+
+// SDiff 第一个 key 和其他 key 的差集
+func (ru *RedisUtil) SDiff(ctx context.Context, keys []string, valuesInter interface{}) (err error) {
+	return ru.getSlice(ctx, valuesInter, "SDIFF", ru.keysPatch(keys)...)
+}
+
+// SInterStore 结果保存到 destination, 返回结果的成员数量
+func (ru *RedisUtil) SInterStore(ctx context.Context, destination string, keys ...string) (res int64, err error) {
+	return ru.setStore(ctx, "SINTERSTORE", destination, keys)
+}
+
+func (ru *RedisUtil) SUnionStore(ctx context.Context, destination string, keys ...string) (res int64, err error) {
+	return ru.setStore(ctx, "SUNIONSTORE", destination, keys)
+}
+
+func (ru *RedisUtil) SDiffStore(ctx context.Context, destination string, keys ...string) (res int64, err error) {
+	return ru.setStore(ctx, "SDIFFSTORE", destination, keys)
+}
+
+func (ru *RedisUtil) setStore(ctx context.Context,
+	command string, destination string, keys []string) (res int64, err error) {
+	args := append([]interface{}{ru.keyPatch(destination)}, ru.keysPatch(keys)...)
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, command, args...))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// SRandMember 随机获取 count 个成员, 不删除, count 为负数时可能重复, 含义同 redis SRANDMEMBER
+func (ru *RedisUtil) SRandMember(ctx context.Context, key string, count int, valuesInter interface{}) (err error) {
+	return ru.getSlice(ctx, valuesInter, "SRANDMEMBER", ru.keyPatch(key), count)
+}
+
+// SPop 随机删除并返回 count 个成员
+func (ru *RedisUtil) SPop(ctx context.Context, key string, count int, valuesInter interface{}) (err error) {
+	if count < 0 {
+		return errors.New("count must not be negative")
+	}
+
+	return ru.getSlice(ctx, valuesInter, "SPOP", ru.keyPatch(key), count)
+}
+
+// 执行返回多个成员的命令并解码到 valuesInter
+func (ru *RedisUtil) getSlice(ctx context.Context,
+	valuesInter interface{}, command string, args ...interface{}) (err error) {
+	var redisResult [][]byte
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		redisResult, err = redis.ByteSlices(conDo(ctx, con, command, args...))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return ru.getMemberCodec().decodeSlice(redisResult, valuesInter)
+}
@@ -0,0 +1,142 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:set"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	n, err := redisUtil.SAdd(ctx, key, 1, 2, 3, 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(3), n)
+
+	members := make([]int, 0)
+	err = redisUtil.SMembers(ctx, key, &members)
+	assert.Equal(t, nil, err)
+	sort.Ints(members)
+	assert.Equal(t, []int{1, 2, 3}, members)
+
+	isMember, err := redisUtil.SIsMember(ctx, key, 2)
+	assert.Equal(t, nil, err)
+	assert.True(t, isMember)
+
+	isMembers, err := redisUtil.SMIsMember(ctx, key, 1, 4, 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false, true}, isMembers)
+
+	n, err = redisUtil.SRem(ctx, key, 2, 4)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	n, _ = redisUtil.SCard(ctx, key)
+	assert.Equal(t, int64(2), n)
+
+	err = redisUtil.SRandMember(ctx, key, 5, &members)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(members))
+
+	err = redisUtil.SPop(ctx, key, 1, &members)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(members))
+
+	n, _ = redisUtil.SCard(ctx, key)
+	assert.Equal(t, int64(1), n)
+}
+
+// 成员不使用信封和压缩, schema version 变化或者值超过压缩阈值时 SIsMember 仍然可以匹配
+func TestSetMemberCodec(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtilV1 := NewRedisUtil(getTestPool(), OptionEnvelope(1), OptionCompress(&GzipCompressor{}, 1))
+	redisUtilV2 := NewRedisUtil(getTestPool(), OptionEnvelope(2), OptionCompress(&GzipCompressor{}, 1))
+	key := "gotest:redis_util:set_member"
+
+	defer func() {
+		_ = redisUtilV1.Del(ctx, key)
+	}()
+
+	member := strings.Repeat("member", 100)
+
+	_, err := redisUtilV1.SAdd(ctx, key, member, "other")
+	assert.Equal(t, nil, err)
+
+	isMember, err := redisUtilV2.SIsMember(ctx, key, member)
+	assert.Equal(t, nil, err)
+	assert.True(t, isMember)
+
+	isMembers, err := redisUtilV2.SMIsMember(ctx, key, member, "none")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false}, isMembers)
+
+	n, err := redisUtilV2.SRem(ctx, key, member)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	members := make([]string, 0)
+	err = redisUtilV2.SMembers(ctx, key, &members)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"other"}, members)
+}
+
+func TestSetCombine(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key1 := "gotest:redis_util:set:1"
+	key2 := "gotest:redis_util:set:2"
+	dest := "gotest:redis_util:set:dest"
+
+	defer func() {
+		for _, key := range []string{key1, key2, dest} {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	_, _ = redisUtil.SAdd(ctx, key1, "a", "b", "c")
+	_, _ = redisUtil.SAdd(ctx, key2, "b", "c", "d")
+
+	members := make([]string, 0)
+
+	err := redisUtil.SInter(ctx, []string{key1, key2}, &members)
+	assert.Equal(t, nil, err)
+	sort.Strings(members)
+	assert.Equal(t, []string{"b", "c"}, members)
+
+	err = redisUtil.SUnion(ctx, []string{key1, key2}, &members)
+	assert.Equal(t, nil, err)
+	sort.Strings(members)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, members)
+
+	err = redisUtil.SDiff(ctx, []string{key1, key2}, &members)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"a"}, members)
+
+	n, err := redisUtil.SInterStore(ctx, dest, key1, key2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = redisUtil.SUnionStore(ctx, dest, key1, key2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(4), n)
+
+	n, err = redisUtil.SDiffStore(ctx, dest, key2, key1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	isMember, _ := redisUtil.SIsMember(ctx, dest, "d")
+	assert.True(t, isMember)
+}
@@ -3,8 +3,10 @@ package redisutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -139,9 +141,10 @@ func TestBatchSet(t *testing.T) {
 	values := []interface{}{data, data}
 	expireSecondsSlice := []int{600, 900}
 
-	err := redisUtil.BatchSet(ctx, &BatchSetParams{
+	result, err := redisUtil.BatchSet(ctx, &BatchSetParams{
 		Keys: keys, Values: values, ExpireSecondsSlice: expireSecondsSlice})
 	assert.Equal(t, nil, err)
+	assert.Equal(t, keys, result.SuccessKeys())
 
 	mgetResult := make([]string, len(keys))
 
@@ -159,6 +162,40 @@ func TestBatchSet(t *testing.T) {
 	}
 }
 
+func TestBatchSetPartialFailed(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+
+	key1 := "gotest:redis_util:TestBatchSetPartialFailed1"
+	key2 := "gotest:redis_util:TestBatchSetPartialFailed2"
+	key3 := "gotest:redis_util:TestBatchSetPartialFailed3"
+
+	data := "aaaaaaaaa"
+
+	keys := []string{key1, key2, key3}
+	values := []interface{}{data, data, data}
+	expireSecondsSlice := []int{600, 0, 900} // 过期时间为0 写入失败
+
+	result, err := redisUtil.BatchSet(ctx, &BatchSetParams{
+		Keys: keys, Values: values, ExpireSecondsSlice: expireSecondsSlice})
+	assert.True(t, errors.Is(err, ErrBatchSetPartialFailed))
+	assert.Equal(t, []string{key1, key3}, result.SuccessKeys())
+	assert.Equal(t, []string{key2}, result.FailedKeys())
+	assert.NotNil(t, result.Errors[1])
+
+	// 连接中没有残留的回复
+	mgetResult := make([]string, len(keys))
+
+	hits, err := redisUtil.MGet(ctx, keys, &mgetResult)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []bool{true, false, true}, hits)
+
+	for _, key := range keys {
+		_ = redisUtil.Del(ctx, key)
+	}
+}
+
 func TestMGet(t *testing.T) {
 	ctx := context.Background()
 	redisUtil := NewRedisUtil(getTestPool())
@@ -252,3 +289,112 @@ func TestMGetStruct(t *testing.T) {
 		_ = redisUtil.Del(ctx, key)
 	}
 }
+
+func TestContextTimeoutCancel(t *testing.T) {
+	redisUtil := NewRedisUtil(getTestPool())
+	redisKey := "gotest:redis_util:ctx_timeout"
+
+	value := ""
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+
+	time.Sleep(time.Millisecond)
+
+	hit, err := redisUtil.Get(timeoutCtx, redisKey, &value)
+	assert.Equal(t, false, hit)
+	assert.True(t, IsTimeout(err))
+	assert.False(t, IsCanceled(err))
+
+	cancelCtx, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+
+	err = redisUtil.Set(cancelCtx, redisKey, "aaa", 600)
+	assert.True(t, IsCanceled(err))
+	assert.False(t, IsTimeout(err))
+
+	// key 不存在不是超时错误
+	hit, err = redisUtil.Get(context.Background(), redisKey, &value)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, hit)
+}
+
+func TestKeyNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := "gotest_key_namespace"
+	redisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 3))
+	redisKey := "cclehui_test_key"
+
+	defer func() {
+		_ = NewRedisUtil(getTestPool()).Del(ctx, namespace+":keyversion")
+	}()
+
+	err := redisUtil.Set(ctx, redisKey, "aaa", 600)
+	assert.Equal(t, nil, err)
+
+	value := ""
+	hit, err := NewRedisUtil(getTestPool()).Get(ctx, namespace+":v3:"+redisKey, &value)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, hit)
+	assert.Equal(t, "aaa", value)
+
+	// 升级版本后所有 key 失效
+	version, err := redisUtil.BumpKeyVersion(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(4), version)
+
+	hit, err = redisUtil.Get(ctx, redisKey, &value)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, hit)
+
+	// 其他进程同步版本
+	otherRedisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 3))
+	version, err = otherRedisUtil.SyncKeyVersion(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(4), version)
+	assert.Equal(t, int64(4), otherRedisUtil.KeyVersion())
+
+	_ = NewRedisUtil(getTestPool()).Del(ctx, namespace+":v3:"+redisKey)
+}
+
+func TestKeyVersionSync(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := "gotest_key_version_sync"
+	channel := "gotest:redis_util:key_version_sync"
+
+	defer func() {
+		_ = NewRedisUtil(getTestPool()).Del(ctx, namespace+":keyversion")
+	}()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 1),
+		OptionLocalCache(100, time.Minute), OptionInvalidation(channel))
+	defer redisUtil.Close()
+
+	// 后台定期同步
+	syncRedisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 1),
+		OptionKeyVersionSync(50*time.Millisecond))
+	defer syncRedisUtil.Close()
+
+	// 通过 pub/sub 同步, 没有开启定期同步和一级缓存
+	subscribeRedisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 1), OptionInvalidation(channel))
+	defer subscribeRedisUtil.Close()
+
+	time.Sleep(time.Millisecond * 100) // 等待订阅成功
+
+	version, err := redisUtil.BumpKeyVersion(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), version)
+
+	time.Sleep(time.Millisecond * 200)
+
+	assert.Equal(t, int64(2), syncRedisUtil.KeyVersion())
+	assert.Equal(t, int64(2), subscribeRedisUtil.KeyVersion())
+
+	// 新创建的实例不需要调用 SyncKeyVersion
+	newRedisUtil := NewRedisUtil(getTestPool(), OptionKeyNamespace(namespace, 1))
+	defer newRedisUtil.Close()
+
+	assert.Equal(t, int64(2), newRedisUtil.KeyVersion())
+}
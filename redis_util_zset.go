@@ -0,0 +1,390 @@
+package redisutil
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// ZAddOptions 对应 ZADD 的 NX, XX, GT, LT, CH 参数, 组合限制同 redis
+type ZAddOptions struct {
+	NX bool // 只新增, 不更新已存在的成员
+	XX bool // 只更新已存在的成员, 不新增
+	GT bool // 新分数大于当前分数时才更新
+	LT bool // 新分数小于当前分数时才更新
+	CH bool // 返回值为变化(新增+更新)的成员数量, 默认只返回新增的数量
+}
+
+func (opts *ZAddOptions) args(key string) []interface{} {
+	args := []interface{}{key}
+	if opts == nil {
+		return args
+	}
+
+	if opts.NX {
+		args = append(args, "NX")
+	}
+
+	if opts.XX {
+		args = append(args, "XX")
+	}
+
+	if opts.GT {
+		args = append(args, "GT")
+	}
+
+	if opts.LT {
+		args = append(args, "LT")
+	}
+
+	if opts.CH {
+		args = append(args, "CH")
+	}
+
+	return args
+}
+
+// ZAddWithOptions 返回新增的成员数量, opts.CH 为 true 时返回变化的成员数量
+func (ru *RedisUtil) ZAddWithOptions(ctx context.Context,
+	key string, opts *ZAddOptions, infos []*SortSetInfo) (res int64, err error) {
+	if len(infos) < 1 {
+		return 0, nil
+	}
+
+	args := opts.args(ru.keyPatch(key))
+	for _, item := range infos {
+		args = append(args, item.Score, item.Name)
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "ZADD", args...))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// ZAddIncr ZADD INCR, 返回增加后的分数, 因为 NX/XX/GT/LT 条件没有执行时 hit 为 false
+func (ru *RedisUtil) ZAddIncr(ctx context.Context,
+	key string, opts *ZAddOptions, info *SortSetInfo) (score float64, hit bool, err error) {
+	args := opts.args(ru.keyPatch(key))
+	args = append(args, "INCR", info.Score, info.Name)
+
+	return ru.getScore(ctx, "ZADD", args...)
+}
+
+func (ru *RedisUtil) ZIncrBy(ctx context.Context, key string, increment float64, member string) (score float64, err error) {
+	score, _, err = ru.getScore(ctx, "ZINCRBY", ru.keyPatch(key), increment, member)
+
+	return score, err
+}
+
+// ZScore 成员不存在时 hit 为 false
+func (ru *RedisUtil) ZScore(ctx context.Context, key string, member string) (score float64, hit bool, err error) {
+	return ru.getScore(ctx, "ZSCORE", ru.keyPatch(key), member)
+}
+
+func (ru *RedisUtil) getScore(ctx context.Context,
+	command string, args ...interface{}) (score float64, hit bool, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		score, err = redis.Float64(conDo(ctx, con, command, args...))
+
+		return err
+	})
+
+	if err == redis.ErrNil {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	return score, true, nil
+}
+
+// ZMScore 结果和 members 一一对应, 成员不存在时 hits 中对应的值为 false, 需要 redis 6.2 及以上
+func (ru *RedisUtil) ZMScore(ctx context.Context,
+	key string, members ...string) (scores []float64, hits []bool, err error) {
+	if len(members) < 1 {
+		return []float64{}, []bool{}, nil
+	}
+
+	args := make([]interface{}, 0, len(members)+1)
+	args = append(args, ru.keyPatch(key))
+
+	for _, member := range members {
+		args = append(args, member)
+	}
+
+	var replies []interface{}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		replies, err = redis.Values(conDo(ctx, con, "ZMSCORE", args...))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scores = make([]float64, len(replies))
+	hits = make([]bool, len(replies))
+
+	for i, reply := range replies {
+		if reply == nil {
+			continue
+		}
+
+		scores[i], err = redis.Float64(reply, nil)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+
+		hits[i] = true
+	}
+
+	return scores, hits, nil
+}
+
+// ZRank 按分数从小到大的排名, 从 0 开始, 成员不存在时 hit 为 false
+func (ru *RedisUtil) ZRank(ctx context.Context, key string, member string) (rank int64, hit bool, err error) {
+	return ru.getRank(ctx, "ZRANK", key, member)
+}
+
+// ZRevRank 按分数从大到小的排名, 从 0 开始
+func (ru *RedisUtil) ZRevRank(ctx context.Context, key string, member string) (rank int64, hit bool, err error) {
+	return ru.getRank(ctx, "ZREVRANK", key, member)
+}
+
+func (ru *RedisUtil) getRank(ctx context.Context,
+	command string, key string, member string) (rank int64, hit bool, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		rank, err = redis.Int64(conDo(ctx, con, command, ru.keyPatch(key), member))
+
+		return err
+	})
+
+	if err == redis.ErrNil {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	return rank, true, nil
+}
+
+// ZRangeWithScores 按排名获取成员和分数, 分数从小到大
+func (ru *RedisUtil) ZRangeWithScores(ctx context.Context,
+	key string, start, end int) (result []*SortSetInfo, err error) {
+	return ru.getSortSetInfos(ctx, "ZRANGE", ru.keyPatch(key), start, end, "WITHSCORES")
+}
+
+// ZRevRangeWithScores 按排名获取成员和分数, 分数从大到小
+func (ru *RedisUtil) ZRevRangeWithScores(ctx context.Context,
+	key string, start, end int) (result []*SortSetInfo, err error) {
+	return ru.getSortSetInfos(ctx, "ZREVRANGE", ru.keyPatch(key), start, end, "WITHSCORES")
+}
+
+// ZRangeBy 按分数或字典序范围查询的参数
+// 分数: Min, Max 支持 "1.5", "(1.5" (开区间), "-inf", "+inf", 为空时分别为 "-inf", "+inf"
+// 字典序: Min, Max 支持 "[a" (闭区间), "(a" (开区间), "-", "+", 为空时分别为 "-", "+"
+// Count 大于 0 时使用 LIMIT Offset Count
+type ZRangeBy struct {
+	Min    string
+	Max    string
+	Offset int64
+	Count  int64
+}
+
+func (by *ZRangeBy) bounds(defaultMin, defaultMax string) (min, max string) {
+	min, max = defaultMin, defaultMax
+	if by == nil {
+		return min, max
+	}
+
+	if by.Min != "" {
+		min = by.Min
+	}
+
+	if by.Max != "" {
+		max = by.Max
+	}
+
+	return min, max
+}
+
+func (by *ZRangeBy) appendLimit(args []interface{}) []interface{} {
+	if by == nil || by.Count <= 0 {
+		return args
+	}
+
+	return append(args, "LIMIT", by.Offset, by.Count)
+}
+
+// ZRangeByScore 按分数范围获取成员和分数, 分数从小到大
+func (ru *RedisUtil) ZRangeByScore(ctx context.Context,
+	key string, by *ZRangeBy) (result []*SortSetInfo, err error) {
+	min, max := by.bounds("-inf", "+inf")
+	args := by.appendLimit([]interface{}{ru.keyPatch(key), min, max, "WITHSCORES"})
+
+	return ru.getSortSetInfos(ctx, "ZRANGEBYSCORE", args...)
+}
+
+// ZRevRangeByScore 按分数范围获取成员和分数, 分数从大到小
+func (ru *RedisUtil) ZRevRangeByScore(ctx context.Context,
+	key string, by *ZRangeBy) (result []*SortSetInfo, err error) {
+	min, max := by.bounds("-inf", "+inf")
+	args := by.appendLimit([]interface{}{ru.keyPatch(key), max, min, "WITHSCORES"})
+
+	return ru.getSortSetInfos(ctx, "ZREVRANGEBYSCORE", args...)
+}
+
+// ZRangeByLex 按字典序范围获取成员, 要求所有成员的分数相同
+func (ru *RedisUtil) ZRangeByLex(ctx context.Context, key string, by *ZRangeBy) (result []string, err error) {
+	min, max := by.bounds("-", "+")
+	args := by.appendLimit([]interface{}{ru.keyPatch(key), min, max})
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		result, err = redis.Strings(conDo(ctx, con, "ZRANGEBYLEX", args...))
+
+		return errors.WithStack(err)
+	})
+
+	return result, err
+}
+
+// ZCount 分数在 min, max 之间的成员数量, min, max 格式同 ZRangeBy
+func (ru *RedisUtil) ZCount(ctx context.Context, key string, min, max string) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "ZCOUNT", ru.keyPatch(key), min, max))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// ZPopMin 删除并返回分数最小的 count 个成员
+func (ru *RedisUtil) ZPopMin(ctx context.Context, key string, count int) (result []*SortSetInfo, err error) {
+	return ru.getSortSetInfos(ctx, "ZPOPMIN", ru.keyPatch(key), count)
+}
+
+// ZPopMax 删除并返回分数最大的 count 个成员
+func (ru *RedisUtil) ZPopMax(ctx context.Context, key string, count int) (result []*SortSetInfo, err error) {
+	return ru.getSortSetInfos(ctx, "ZPOPMAX", ru.keyPatch(key), count)
+}
+
+// 执行 WITHSCORES 的命令, 结果为 成员, 分数 交替的列表
+func (ru *RedisUtil) getSortSetInfos(ctx context.Context,
+	command string, args ...interface{}) (result []*SortSetInfo, err error) {
+	var replies []string
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		replies, err = redis.Strings(conDo(ctx, con, command, args...))
+
+		return errors.WithStack(err)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(replies)%2 != 0 {
+		return nil, errors.Errorf("%s, expects even number of values result, got %d", command, len(replies))
+	}
+
+	result = make([]*SortSetInfo, 0, len(replies)/2)
+
+	for i := 0; i < len(replies); i += 2 {
+		score, err := strconv.ParseFloat(replies[i+1], 64)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		result = append(result, &SortSetInfo{Score: score, Name: replies[i]})
+	}
+
+	return result, nil
+}
+
+// ZRemRangeByScore 删除分数在 min, max 之间的成员, 返回删除的数量, min, max 格式同 ZRangeBy
+func (ru *RedisUtil) ZRemRangeByScore(ctx context.Context, key string, min, max string) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "ZREMRANGEBYSCORE", ru.keyPatch(key), min, max))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// ZRemRangeByRank 删除排名在 start, stop 之间的成员(分数从小到大), 返回删除的数量
+func (ru *RedisUtil) ZRemRangeByRank(ctx context.Context, key string, start, stop int) (res int64, err error) {
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, "ZREMRANGEBYRANK", ru.keyPatch(key), start, stop))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
+
+// ZStore ZUNIONSTORE, ZINTERSTORE 的参数
+// Weights 为空时不设置, 否则长度必须和 Keys 相同; Aggregate 为 SUM, MIN, MAX, 为空时为 SUM
+type ZStore struct {
+	Keys      []string
+	Weights   []float64
+	Aggregate string
+}
+
+// ZUnionStore 结果保存到 destination, 返回结果的成员数量
+func (ru *RedisUtil) ZUnionStore(ctx context.Context, destination string, store *ZStore) (res int64, err error) {
+	return ru.zStore(ctx, "ZUNIONSTORE", destination, store)
+}
+
+// ZInterStore 结果保存到 destination, 返回结果的成员数量
+func (ru *RedisUtil) ZInterStore(ctx context.Context, destination string, store *ZStore) (res int64, err error) {
+	return ru.zStore(ctx, "ZINTERSTORE", destination, store)
+}
+
+func (ru *RedisUtil) zStore(ctx context.Context,
+	command string, destination string, store *ZStore) (res int64, err error) {
+	if store == nil || len(store.Keys) < 1 {
+		return 0, errors.New("keys is empty")
+	}
+
+	if len(store.Weights) > 0 && len(store.Weights) != len(store.Keys) {
+		return 0, errors.New("the number of weights must be the same as keys")
+	}
+
+	args := make([]interface{}, 0, len(store.Keys)*2+5)
+	args = append(args, ru.keyPatch(destination), len(store.Keys))
+	args = append(args, ru.keysPatch(store.Keys)...)
+
+	if len(store.Weights) > 0 {
+		args = append(args, "WEIGHTS")
+		for _, weight := range store.Weights {
+			args = append(args, weight)
+		}
+	}
+
+	if store.Aggregate != "" {
+		args = append(args, "AGGREGATE", strings.ToUpper(store.Aggregate))
+	}
+
+	err = ru.WrapDo(ctx, func(con redis.Conn) error {
+		res, err = redis.Int64(conDo(ctx, con, command, args...))
+
+		return errors.WithStack(err)
+	})
+
+	return res, err
+}
@@ -0,0 +1,190 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZSetScore(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:zset_score"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	n, err := redisUtil.ZAddWithOptions(ctx, key, nil, []*SortSetInfo{
+		{Score: 1.5, Name: "a"},
+		{Score: 2, Name: "b"},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), n)
+
+	// NX 不更新已存在的成员
+	n, err = redisUtil.ZAddWithOptions(ctx, key, &ZAddOptions{NX: true, CH: true}, []*SortSetInfo{
+		{Score: 10, Name: "a"},
+		{Score: 3, Name: "c"},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	// GT 只在分数变大时更新
+	n, err = redisUtil.ZAddWithOptions(ctx, key, &ZAddOptions{XX: true, GT: true, CH: true}, []*SortSetInfo{
+		{Score: 1, Name: "a"},
+		{Score: 5, Name: "b"},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	score, hit, err := redisUtil.ZScore(ctx, key, "b")
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, float64(5), score)
+
+	_, hit, err = redisUtil.ZScore(ctx, key, "not_exist")
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+
+	scores, hits, err := redisUtil.ZMScore(ctx, key, "a", "not_exist", "c")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []float64{1.5, 0, 3}, scores)
+	assert.Equal(t, []bool{true, false, true}, hits)
+
+	score, hit, err = redisUtil.ZAddIncr(ctx, key, nil, &SortSetInfo{Score: 0.5, Name: "a"})
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, float64(2), score)
+
+	_, hit, err = redisUtil.ZAddIncr(ctx, key, &ZAddOptions{NX: true}, &SortSetInfo{Score: 1, Name: "a"})
+	assert.Equal(t, nil, err)
+	assert.False(t, hit)
+
+	score, err = redisUtil.ZIncrBy(ctx, key, -1, "c")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, float64(2), score)
+
+	// a:2 c:2 b:5
+	rank, hit, err := redisUtil.ZRank(ctx, key, "b")
+	assert.Equal(t, nil, err)
+	assert.True(t, hit)
+	assert.Equal(t, int64(2), rank)
+
+	rank, _, _ = redisUtil.ZRevRank(ctx, key, "b")
+	assert.Equal(t, int64(0), rank)
+
+	_, hit, _ = redisUtil.ZRank(ctx, key, "not_exist")
+	assert.False(t, hit)
+}
+
+func TestZSetRange(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key := "gotest:redis_util:zset_range"
+
+	defer func() {
+		_ = redisUtil.Del(ctx, key)
+	}()
+
+	err := redisUtil.ZAdd(ctx, key, []*SortSetInfo{
+		{Score: 1, Name: "a"},
+		{Score: 2, Name: "b"},
+		{Score: 3, Name: "c"},
+		{Score: 4, Name: "d"},
+	})
+	assert.Equal(t, nil, err)
+
+	infos, err := redisUtil.ZRangeWithScores(ctx, key, 0, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 1, Name: "a"}, {Score: 2, Name: "b"}}, infos)
+
+	infos, err = redisUtil.ZRevRangeWithScores(ctx, key, 0, 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 4, Name: "d"}}, infos)
+
+	infos, err = redisUtil.ZRangeByScore(ctx, key, &ZRangeBy{Min: "(1", Max: "3"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 2, Name: "b"}, {Score: 3, Name: "c"}}, infos)
+
+	infos, err = redisUtil.ZRangeByScore(ctx, key, &ZRangeBy{Offset: 1, Count: 2})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 2, Name: "b"}, {Score: 3, Name: "c"}}, infos)
+
+	infos, err = redisUtil.ZRevRangeByScore(ctx, key, &ZRangeBy{Max: "(4", Count: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 3, Name: "c"}}, infos)
+
+	n, err := redisUtil.ZCount(ctx, key, "2", "+inf")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(3), n)
+
+	infos, err = redisUtil.ZPopMin(ctx, key, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 1, Name: "a"}}, infos)
+
+	infos, err = redisUtil.ZPopMax(ctx, key, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []*SortSetInfo{{Score: 4, Name: "d"}}, infos)
+
+	n, err = redisUtil.ZRemRangeByScore(ctx, key, "-inf", "2")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = redisUtil.ZRemRangeByRank(ctx, key, 0, -1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestZSetLexAndStore(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool())
+	key1 := "gotest:redis_util:zset_store:1"
+	key2 := "gotest:redis_util:zset_store:2"
+	dest := "gotest:redis_util:zset_store:dest"
+
+	defer func() {
+		for _, key := range []string{key1, key2, dest} {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	_ = redisUtil.ZAdd(ctx, key1, []*SortSetInfo{
+		{Score: 0, Name: "a"},
+		{Score: 0, Name: "b"},
+		{Score: 0, Name: "c"},
+	})
+
+	names, err := redisUtil.ZRangeByLex(ctx, key1, &ZRangeBy{Min: "(a", Max: "[c"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"b", "c"}, names)
+
+	names, err = redisUtil.ZRangeByLex(ctx, key1, &ZRangeBy{Count: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"a"}, names)
+
+	_ = redisUtil.ZAdd(ctx, key1, []*SortSetInfo{{Score: 1, Name: "a"}, {Score: 2, Name: "b"}})
+	_ = redisUtil.ZAdd(ctx, key2, []*SortSetInfo{{Score: 10, Name: "b"}, {Score: 20, Name: "d"}})
+
+	n, err := redisUtil.ZUnionStore(ctx, dest, &ZStore{Keys: []string{key1, key2}, Weights: []float64{1, 2}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(4), n)
+
+	score, _, _ := redisUtil.ZScore(ctx, dest, "b")
+	assert.Equal(t, float64(22), score)
+
+	n, err = redisUtil.ZInterStore(ctx, dest, &ZStore{Keys: []string{key1, key2}, Aggregate: "max"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), n)
+
+	score, _, _ = redisUtil.ZScore(ctx, dest, "b")
+	assert.Equal(t, float64(10), score)
+
+	_, err = redisUtil.ZUnionStore(ctx, dest, &ZStore{Keys: []string{key1}, Weights: []float64{1, 2}})
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,30 @@
+package redisutil
+
+// TTLJitter 过期时间随机延长, 避免同时写入的大量 key 在同一时刻过期
+// Percent 和 Seconds 二选一, 都设置时使用 Percent, 都为0 时不延长
+type TTLJitter struct {
+	Percent float64 // 按比例延长, 0.1 表示随机延长 0~10%
+	Seconds int     // 按绝对值延长, 随机延长 0~Seconds 秒
+}
+
+// jitter 为空时使用 OptionTTLJitter 的配置, 不过期的 key 不处理
+func (ru *RedisUtil) jitterTTL(ttl int, jitter *TTLJitter) int {
+	if jitter == nil {
+		jitter = ru.ttlJitter
+	}
+
+	if jitter == nil || ttl <= 0 {
+		return ttl
+	}
+
+	maxJitter := jitter.Seconds
+	if jitter.Percent > 0 {
+		maxJitter = int(float64(ttl) * jitter.Percent)
+	}
+
+	if maxJitter <= 0 {
+		return ttl
+	}
+
+	return ttl + ru.rand.intn(maxJitter+1)
+}
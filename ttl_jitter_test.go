@@ -0,0 +1,111 @@
+//nolint:goconst
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterTTL(t *testing.T) {
+	redisUtil := NewRedisUtil(getTestPool(), OptionTTLJitter(TTLJitter{Seconds: 10}), OptionRandSeed(1))
+	otherRedisUtil := NewRedisUtil(getTestPool(), OptionTTLJitter(TTLJitter{Seconds: 10}), OptionRandSeed(1))
+
+	for i := 0; i < 100; i++ {
+		ttl := redisUtil.jitterTTL(100, nil)
+		assert.True(t, ttl >= 100 && ttl <= 110)
+
+		// 相同的种子得到相同的结果
+		assert.Equal(t, ttl, otherRedisUtil.jitterTTL(100, nil))
+
+		ttl = redisUtil.jitterTTL(100, &TTLJitter{Percent: 0.5})
+		assert.True(t, ttl >= 100 && ttl <= 150)
+		assert.Equal(t, ttl, otherRedisUtil.jitterTTL(100, &TTLJitter{Percent: 0.5}))
+	}
+
+	// 零值不延长, 不过期的 key 不处理
+	assert.Equal(t, 100, redisUtil.jitterTTL(100, &TTLJitter{}))
+	assert.Equal(t, TTLNoExpire, redisUtil.jitterTTL(TTLNoExpire, nil))
+}
+
+func TestTTLJitter(t *testing.T) {
+	ctx := context.Background()
+
+	redisUtil := NewRedisUtil(getTestPool(), OptionTTLJitter(TTLJitter{Percent: 0.5}), OptionRandSeed(1))
+
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("gotest:redis_util:ttl_jitter%d", i)
+	}
+
+	defer func() {
+		for _, key := range keys {
+			_ = redisUtil.Del(ctx, key)
+		}
+	}()
+
+	assertTTLs := func() {
+		ttls := make(map[int]bool)
+
+		for _, key := range keys {
+			ttl, err := redisUtil.TTL(ctx, key)
+			assert.Equal(t, nil, err)
+			assert.True(t, ttl >= 99 && ttl <= 150) // 读取时可能已经过去了1秒
+
+			ttls[ttl] = true
+		}
+
+		assert.True(t, len(ttls) > 1)
+	}
+
+	for _, key := range keys {
+		_ = redisUtil.Set(ctx, key, "value", 100)
+	}
+
+	assertTTLs()
+
+	expireSecondsSlice := make([]int, len(keys))
+	values := make([]interface{}, len(keys))
+
+	for i := range keys {
+		expireSecondsSlice[i] = 100
+		values[i] = "value"
+	}
+
+	_, err := redisUtil.BatchSet(ctx, &BatchSetParams{
+		Keys: keys, Values: values, ExpireSecondsSlice: expireSecondsSlice,
+	})
+	assert.Equal(t, nil, err)
+	assertTTLs()
+
+	// 单次覆盖
+	_, err = redisUtil.BatchSet(ctx, &BatchSetParams{
+		Keys: keys, Values: values, ExpireSecondsSlice: expireSecondsSlice, TTLJitter: &TTLJitter{},
+	})
+	assert.Equal(t, nil, err)
+
+	for _, key := range keys {
+		ttl, _ := redisUtil.TTL(ctx, key)
+		assert.True(t, ttl >= 99 && ttl <= 100)
+
+		_ = redisUtil.Del(ctx, key)
+	}
+
+	dataResult := make([]string, len(keys))
+
+	err = redisUtil.CacheWrapperMget(ctx, &WrapperParamsMget{
+		Keys: keys, ExpireSeconds: expireSecondsSlice, ResultSlice: &dataResult,
+		BatchFallbackFunc: func(fallbackIndexes []int) (map[int]interface{}, error) {
+			result := make(map[int]interface{})
+			for _, i := range fallbackIndexes {
+				result[i] = "value"
+			}
+
+			return result, nil
+		},
+	})
+	assert.Equal(t, nil, err)
+	assertTTLs()
+}